@@ -0,0 +1,174 @@
+package taskq
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnknownDelivery is returned by Ack and Nack of delivery which was already settled
+var ErrUnknownDelivery = errors.New("unknown delivery")
+
+// Delivery is a task dequeued from AckQueue. Task stays in queue until it is acknowledged
+type Delivery interface {
+	Item() Item
+	// Ack removes task from queue
+	Ack(ctx context.Context) error
+	// Nack returns task into queue for redelivery after delay. Zero delay requeues task immediately
+	Nack(ctx context.Context, delay time.Duration) error
+}
+
+// RetryDelivery is an optional Delivery extension for queues which keep retries of failed tasks.
+// Retry returns task into queue like Nack and increments Item.Retries, so TaskQ can tell retries from redeliveries
+type RetryDelivery interface {
+	Retry(ctx context.Context, delay time.Duration) error
+}
+
+// AckQueue is an optional Queue extension for at-least-once delivery.
+// TaskQ acknowledges delivery when task succeeded, failed after all retries or was dead-lettered.
+// Failed task with remaining retries is returned into queue with RetryDelivery.Retry and retry delay,
+// task interrupted by Shutdown is returned into queue with Nack as well
+type AckQueue interface {
+	DequeueDelivery(ctx context.Context) (Delivery, error)
+}
+
+func (t *TaskQ) dequeueDelivery(ctx context.Context, q AckQueue) (*job, error) {
+	d, err := q.DequeueDelivery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.dequeued()
+	j := &job{Item: d.Item(), delivery: d}
+	j.attempt = j.Retries + 1
+	j.stopLease = t.heartbeat(j)
+	return j, nil
+}
+
+// settle acknowledges delivery of finished job which won't be retried.
+// Job interrupted by TaskQ shutdown is returned into queue
+func (t *TaskQ) settle(j *job, err error) {
+	if j.delivery == nil {
+		return
+	}
+	j.stopLease()
+	if err != nil && t.ctx.Err() != nil {
+		t.requeue(j)
+		return
+	}
+	t.ackError(j, j.delivery.Ack(context.Background()))
+}
+
+// requeue returns job into queue with the remaining delay
+func (t *TaskQ) requeue(j *job) {
+	if j.delivery == nil {
+		return
+	}
+	j.stopLease()
+	var delay time.Duration
+	if !j.at.IsZero() {
+		delay = time.Until(j.at)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	t.ackError(j, j.delivery.Nack(context.Background(), delay))
+}
+
+func (t *TaskQ) ackError(j *job, err error) {
+	if err != nil && t.OnAckError != nil {
+		t.OnAckError(context.Background(), j.ID, err)
+	}
+}
+
+// ackDelivery is a delivery of ConcurrentQueue
+type ackDelivery struct {
+	q     *ConcurrentQueue
+	it    Item
+	token int64
+}
+
+// unackedItem is a delivered task of ConcurrentQueue
+type unackedItem struct {
+	Item
+	token int64
+	// lease is a time when task is redelivered. Zero means never
+	lease time.Time
+}
+
+func (d *ackDelivery) Item() Item {
+	return d.it
+}
+
+// take removes delivery from unacked tasks. Must be called under lock
+func (d *ackDelivery) take() (Item, error) {
+	u, ok := d.q.unacked[d.it.ID]
+	if !ok || u.token != d.token {
+		return Item{}, ErrUnknownDelivery
+	}
+	delete(d.q.unacked, d.it.ID)
+	return u.Item, nil
+}
+
+func (d *ackDelivery) Ack(_ context.Context) error {
+	d.q.lock.Lock()
+	defer d.q.lock.Unlock()
+	_, err := d.take()
+	return err
+}
+
+func (d *ackDelivery) Nack(_ context.Context, delay time.Duration) error {
+	return d.nack(delay, false)
+}
+
+func (d *ackDelivery) Retry(_ context.Context, delay time.Duration) error {
+	return d.nack(delay, true)
+}
+
+func (d *ackDelivery) nack(delay time.Duration, retry bool) error {
+	d.q.lock.Lock()
+	defer d.q.lock.Unlock()
+	it, err := d.take()
+	if err != nil {
+		return err
+	}
+	if retry {
+		it.Retries++
+	}
+	if delay <= 0 {
+		d.q.queue = append(d.q.queue, it)
+		return nil
+	}
+	heap.Push(&d.q.scheduled, &job{Item: it, at: time.Now().Add(delay)})
+	return nil
+}
+
+// DequeueDelivery takes the first task from queue. Task is kept in queue until Ack or Nack
+// or until ConcurrentQueue.VisibilityTimeout expires
+func (q *ConcurrentQueue) DequeueDelivery(_ context.Context) (Delivery, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.reclaim()
+	it, ok := q.pop()
+	if !ok {
+		return nil, EmptyQueue
+	}
+	if q.unacked == nil {
+		q.unacked = make(map[int64]*unackedItem)
+	}
+	it.Deliveries++
+	q.lastToken++
+	u := &unackedItem{Item: it, token: q.lastToken}
+	if q.VisibilityTimeout > 0 {
+		u.lease = time.Now().Add(q.VisibilityTimeout)
+	}
+	q.unacked[it.ID] = u
+	return &ackDelivery{q: q, it: it, token: u.token}, nil
+}
+
+// Unacked returns the number of delivered tasks waiting for Ack or Nack
+func (q *ConcurrentQueue) Unacked() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.unacked)
+}
@@ -0,0 +1,188 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestConcurrentQueueDelivery_AckNack(t *testing.T) {
+	ctx := context.Background()
+	q := taskq.NewConcurrentQueue()
+	id, _ := q.Enqueue(ctx, noopTask())
+	d, err := q.DequeueDelivery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Unacked() != 1 || q.Len(ctx) != 0 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(ctx))
+	}
+	if err := d.Nack(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err = q.DequeueDelivery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Item().ID != id {
+		t.Fatalf("invalid redelivered task. expected=%d got=%d", id, d.Item().ID)
+	}
+	if err := d.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Ack(ctx); err != taskq.ErrUnknownDelivery {
+		t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrUnknownDelivery, err)
+	}
+	if q.Unacked() != 0 || q.Len(ctx) != 0 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(ctx))
+	}
+}
+
+func TestConcurrentQueueDelivery_NackDelay(t *testing.T) {
+	ctx := context.Background()
+	q := taskq.NewConcurrentQueue()
+	q.Enqueue(ctx, noopTask())
+	d, _ := q.DequeueDelivery(ctx)
+	d.Nack(ctx, 20*time.Millisecond)
+	if _, err := q.DequeueDelivery(ctx); err != taskq.EmptyQueue {
+		t.Fatalf("delayed task redelivered too early. err=%v", err)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if _, err := q.DequeueDelivery(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAckQueue_SettledAfterTask(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(2, q)
+	tq.Start()
+	tq.Enqueue(context.Background(), noopTask())
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		return errors.New("test")
+	}))
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	// failed task is handled by OnError and acknowledged as well
+	if q.Unacked() != 0 || q.Len(context.Background()) != 0 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(context.Background()))
+	}
+}
+
+func TestAckQueue_RequeueInterrupted(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.ShutdownGracePeriod = time.Second
+	started := make(chan struct{})
+	tq.Start()
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tq.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected=%s got=%s", context.DeadlineExceeded, err)
+	}
+	// interrupted task is returned into queue for redelivery
+	if q.Unacked() != 0 || q.Len(context.Background()) != 1 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(context.Background()))
+	}
+}
+
+func TestAckQueue_RetryNacked(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.RetryPolicy = taskq.ConstantBackoff(3, 50*time.Millisecond)
+	failed := make(chan int, 3)
+	errs := make(chan error, 1)
+	tq.Start()
+	tq.Enqueue(context.Background(), &testTask{
+		fdo: func(ctx context.Context) error {
+			failed <- taskq.Attempt(ctx)
+			return errors.New("test")
+		},
+		fOnError: func(ctx context.Context, err error) { errs <- err },
+	})
+	<-failed
+	time.Sleep(10 * time.Millisecond)
+	// retry is stored in queue instead of memory
+	if q.Unacked() != 0 || q.Len(context.Background()) != 1 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(context.Background()))
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if a2, a3 := <-failed, <-failed; a2 != 2 || a3 != 3 {
+		t.Fatalf("invalid attempts. expected=2,3 got=%d,%d", a2, a3)
+	}
+	if len(errs) != 1 {
+		t.Fatal("OnError wasn't called after the last attempt")
+	}
+	// delivery is acknowledged after retries are exhausted
+	if q.Unacked() != 0 || q.Len(context.Background()) != 0 {
+		t.Fatalf("invalid state. unacked=%d len=%d", q.Unacked(), q.Len(context.Background()))
+	}
+}
+
+func TestAckQueue_RetryReportedOnClose(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.RetryPolicy = taskq.ConstantBackoff(3, time.Hour)
+	testErr := errors.New("test")
+	errs := make(chan error, 1)
+	tq.Start()
+	f, _ := tq.EnqueueFuture(context.Background(), &testTask{
+		resultErr: testErr,
+		fOnError:  func(ctx context.Context, err error) { errs <- err },
+	})
+	waitFor(t, func() bool {
+		s, _ := tq.Status(context.Background(), f.ID)
+		return s.State == taskq.StateRetrying
+	})
+	if err := tq.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != testErr {
+		t.Fatalf("invalid error. expected=%s got=%v", testErr, err)
+	}
+	if err := f.Wait(context.Background()); err != testErr {
+		t.Fatalf("invalid future error. expected=%s got=%v", testErr, err)
+	}
+	if s, _ := tq.Status(context.Background(), f.ID); s.State != taskq.StateCancelled {
+		t.Fatalf("invalid state. expected=%s got=%s", taskq.StateCancelled, s.State)
+	}
+	// retry stays in queue for redelivery
+	if q.Len(context.Background()) != 1 {
+		t.Fatalf("invalid queue length. expected=1 got=%d", q.Len(context.Background()))
+	}
+}
+
+func TestAckQueue_CancelRetry(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.RetryPolicy = taskq.ConstantBackoff(3, time.Hour)
+	errs := make(chan error, 2)
+	tq.Start()
+	id, _ := tq.Enqueue(context.Background(), &testTask{
+		resultErr: errors.New("test"),
+		fOnError:  func(ctx context.Context, err error) { errs <- err },
+	})
+	waitFor(t, func() bool {
+		s, _ := tq.Status(context.Background(), id)
+		return s.State == taskq.StateRetrying
+	})
+	if err := tq.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	tq.Close()
+	if len(errs) != 1 || <-errs != taskq.ErrTaskCancelled {
+		t.Fatal("cancelled retry must be reported once with ErrTaskCancelled")
+	}
+}
@@ -0,0 +1,168 @@
+package taskq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultAutoscaleInterval is used when Autoscaler.Interval is not positive
+const defaultAutoscaleInterval = 100 * time.Millisecond
+
+// Reasons of ScaleEvent
+const (
+	ScaleUpQueueDepth = "queue_depth"
+	ScaleUpWaitTime   = "wait_time"
+	ScaleDownIdle     = "idle"
+)
+
+// ScaleEvent describes a change of TaskQ worker limit made by Autoscaler
+type ScaleEvent struct {
+	From, To int
+	Reason   string
+	// QueueDepth and OldestAge are the queue state which caused scaling
+	QueueDepth int
+	OldestAge  time.Duration
+}
+
+// Autoscaler resizes TaskQ between Min and Max workers.
+// It scales up when queue depth or waiting time of the oldest task reaches threshold
+// and scales down when workers are idle for IdleTimeout
+type Autoscaler struct {
+	tq       *TaskQ
+	Min, Max int
+	// Interval between checks of queue state. Not positive value means 100ms
+	Interval time.Duration
+	// QueueDepth is a number of waiting tasks for scaling up. Queue must implement SizedQueue. Zero disables
+	QueueDepth int
+	// WaitTime of the oldest task for scaling up. Queue must implement PeekQueue. Zero disables
+	WaitTime time.Duration
+	// IdleTimeout is a time after which idle workers above Min retire
+	IdleTimeout time.Duration
+	OnScale     func(ScaleEvent)
+
+	lock      sync.Mutex
+	stop      chan struct{}
+	idleSince time.Time
+	peakBusy  int
+}
+
+func NewAutoscaler(tq *TaskQ, min, max int) *Autoscaler {
+	if max < min {
+		max = min
+	}
+	return &Autoscaler{
+		tq:          tq,
+		Min:         min,
+		Max:         max,
+		Interval:    defaultAutoscaleInterval,
+		QueueDepth:  1,
+		IdleTimeout: time.Minute,
+	}
+}
+
+// Start resizes TaskQ to Min warm workers and starts checking queue state
+func (a *Autoscaler) Start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.stop != nil {
+		return ErrStarted
+	}
+	if a.Interval <= 0 {
+		a.Interval = defaultAutoscaleInterval
+	}
+	a.stop = make(chan struct{})
+	a.idleSince = time.Now()
+	a.tq.Resize(a.Min)
+	a.tq.KeepIdleWorkers(a.Min, a.IdleTimeout)
+	go a.run(a.stop)
+	return nil
+}
+
+func (a *Autoscaler) Stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+}
+
+func (a *Autoscaler) run(stop chan struct{}) {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-a.tq.closing:
+			return
+		case now := <-ticker.C:
+			a.lock.Lock()
+			a.check(now)
+			a.lock.Unlock()
+		}
+	}
+}
+
+func (a *Autoscaler) queueState() (int, time.Duration) {
+	ctx := context.Background()
+	var depth int
+	var age time.Duration
+	if q, ok := a.tq.queue.(SizedQueue); ok {
+		depth = q.Len(ctx)
+	}
+	if q, ok := a.tq.queue.(PeekQueue); ok {
+		if it, err := q.Peek(ctx); err == nil && !it.EnqueuedAt.IsZero() {
+			age = time.Since(it.EnqueuedAt)
+		}
+	}
+	return depth, age
+}
+
+func (a *Autoscaler) check(now time.Time) {
+	s := a.tq.Stats()
+	depth, age := a.queueState()
+	busy := s.Workers - s.Idle
+	if busy > a.peakBusy {
+		a.peakBusy = busy
+	}
+	// all workers are busy and pool can grow
+	canGrow := busy >= s.Limit && s.Limit < a.Max
+	event := ScaleEvent{From: s.Limit, QueueDepth: depth, OldestAge: age}
+	switch {
+	case canGrow && a.QueueDepth > 0 && depth >= a.QueueDepth:
+		event.Reason = ScaleUpQueueDepth
+	case canGrow && a.WaitTime > 0 && age >= a.WaitTime:
+		event.Reason = ScaleUpWaitTime
+	// queue depth of not saturated pool is tasks which are not due yet, e.g. scheduled tasks and retries
+	case depth == 0 || busy < s.Limit:
+		if now.Sub(a.idleSince) < a.IdleTimeout {
+			return
+		}
+		event.Reason = ScaleDownIdle
+	default:
+		a.idleSince, a.peakBusy = now, 0
+		return
+	}
+
+	if event.Reason == ScaleDownIdle {
+		event.To = a.peakBusy
+		if event.To < a.Min {
+			event.To = a.Min
+		}
+	} else {
+		event.To = s.Limit * 2
+		if event.To > a.Max {
+			event.To = a.Max
+		}
+	}
+	a.idleSince, a.peakBusy = now, 0
+	if event.To == event.From {
+		return
+	}
+	a.tq.Resize(event.To)
+	if a.OnScale != nil {
+		a.OnScale(event)
+	}
+}
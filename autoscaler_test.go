@@ -0,0 +1,105 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestAutoscalerScaleUpAndDown_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	a := taskq.NewAutoscaler(tq, 1, 4)
+	a.Interval = 5 * time.Millisecond
+	a.IdleTimeout = 30 * time.Millisecond
+	events := make(chan taskq.ScaleEvent, 10)
+	a.OnScale = func(e taskq.ScaleEvent) {
+		events <- e
+	}
+	a.Start()
+	defer a.Stop()
+	waitFor(t, func() bool { return tq.Stats().Idle == 1 })
+
+	c := &concurrencyTask{release: make(chan struct{})}
+	for i := 0; i < 8; i++ {
+		tq.Enqueue(context.Background(), c.task())
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&c.active) == 4 })
+	close(c.release)
+	waitFor(t, func() bool { return tq.Limit() == 1 })
+
+	var up, down bool
+	for len(events) > 0 {
+		e := <-events
+		switch e.Reason {
+		case taskq.ScaleUpQueueDepth:
+			up = up || e.To == 4
+		case taskq.ScaleDownIdle:
+			down = down || e.To == 1
+		}
+	}
+	if !up || !down {
+		t.Fatalf("scale events weren't reported. up=%v down=%v", up, down)
+	}
+}
+
+func TestAutoscalerScaleDownWithScheduledTask_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	a := taskq.NewAutoscaler(tq, 1, 4)
+	a.Interval = 5 * time.Millisecond
+	a.IdleTimeout = 30 * time.Millisecond
+	a.Start()
+	defer a.Stop()
+	// task which is not due keeps queue depth above zero
+	tq.EnqueueAfter(context.Background(), noopTask(), time.Hour)
+	c := &concurrencyTask{release: make(chan struct{})}
+	for i := 0; i < 8; i++ {
+		tq.Enqueue(context.Background(), c.task())
+	}
+	waitFor(t, func() bool { return tq.Limit() == 4 })
+	close(c.release)
+	waitFor(t, func() bool { return tq.Limit() == 1 })
+}
+
+func TestAutoscalerZeroInterval_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	a := taskq.NewAutoscaler(tq, 1, 2)
+	a.Interval = 0
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Stop()
+	if a.Interval != 100*time.Millisecond {
+		t.Fatalf("invalid interval. expected=100ms got=%s", a.Interval)
+	}
+}
+
+func TestKeepIdleWorkers_Ok(t *testing.T) {
+	tq := taskq.New(2)
+	tq.KeepIdleWorkers(1, 10*time.Millisecond)
+	tq.Start()
+	waitFor(t, func() bool { return tq.Stats().Idle == 1 && tq.Stats().Workers == 1 })
+	rch := make(chan struct{})
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		close(rch)
+		return nil
+	}))
+	select {
+	case <-rch:
+	case <-time.After(time.Second):
+		t.Fatal("idle worker didn't take the task")
+	}
+	if err := tq.Close(); err != nil {
+		t.Fatal("close:", err)
+	}
+	if w := tq.Stats().Workers; w != 0 {
+		t.Fatalf("idle workers weren't released. workers=%d", w)
+	}
+}
@@ -0,0 +1,77 @@
+package taskq
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// BlockingQueue is an optional Queue extension for queues which can wait for a new task (e.g. BLPOP).
+// One idle worker waits in DequeueWait instead of relying on Enqueue-triggered wakeups.
+// DequeueWait isn't used for AckQueue since Item can't be acknowledged, such queues should implement Notifier
+type BlockingQueue interface {
+	// DequeueWait blocks until task is available or ctx is done.
+	// EmptyQueue means waiting timed out on the queue side and is retried
+	DequeueWait(ctx context.Context) (Item, error)
+}
+
+// Notifier is an optional Queue extension for queues which know when new tasks show up
+type Notifier interface {
+	// Notify registers func which queue calls when new tasks are available
+	Notify(func())
+}
+
+// listen waits for a task in BlockingQueue. Only one worker listens at a time,
+// others get EmptyQueue. Listening is interrupted by notify, Shutdown, Pause and Stop
+func (t *TaskQ) listen() (*job, error) {
+	q, ok := t.queue.(BlockingQueue)
+	// tasks of AckQueue are taken only with delivery
+	if _, ack := t.queue.(AckQueue); ack {
+		ok = false
+	}
+	if !ok || !atomic.CompareAndSwapInt32(&t.listening, 0, 1) {
+		return nil, EmptyQueue
+	}
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.listenLock.Lock()
+	t.listenCancel = cancel
+	t.listenLock.Unlock()
+	it, err := t.waitItem(ctx, q)
+	t.stopListen()
+	atomic.StoreInt32(&t.listening, 0)
+	if err != nil {
+		return nil, err
+	}
+	t.dequeued()
+	// hand listening over to a free worker
+	t.triggerDequeue()
+	j := &job{Item: it, attempt: 1}
+	t.nextLock.Lock()
+	defer t.nextLock.Unlock()
+	return j, t.enterPartition(j)
+}
+
+func (t *TaskQ) waitItem(ctx context.Context, q BlockingQueue) (Item, error) {
+	for {
+		// new job could be added, Shutdown or Pause started before listening began
+		if atomic.LoadInt32(&t.signal) == 1 || atomic.LoadInt32(&t.isClosed) == 1 || t.halted() {
+			return Item{}, EmptyQueue
+		}
+		it, err := q.DequeueWait(ctx)
+		if ctx.Err() != nil && err != nil {
+			return Item{}, EmptyQueue
+		}
+		if err != EmptyQueue {
+			return it, err
+		}
+	}
+}
+
+// stopListen interrupts the worker waiting in BlockingQueue
+func (t *TaskQ) stopListen() {
+	t.listenLock.Lock()
+	if t.listenCancel != nil {
+		t.listenCancel()
+		t.listenCancel = nil
+	}
+	t.listenLock.Unlock()
+}
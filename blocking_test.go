@@ -0,0 +1,164 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+// blockingQueue is a channel based queue fed by other processes
+type blockingQueue struct {
+	ch    chan taskq.Task
+	waits int32
+}
+
+func (q *blockingQueue) Enqueue(_ context.Context, t taskq.Task) (int64, error) {
+	q.ch <- t
+	return 1, nil
+}
+
+func (q *blockingQueue) Dequeue(_ context.Context) (taskq.Task, error) {
+	select {
+	case t := <-q.ch:
+		return t, nil
+	default:
+		return nil, taskq.EmptyQueue
+	}
+}
+
+func (q *blockingQueue) DequeueWait(ctx context.Context) (taskq.Item, error) {
+	atomic.AddInt32(&q.waits, 1)
+	select {
+	case t := <-q.ch:
+		return taskq.Item{Task: t}, nil
+	case <-ctx.Done():
+		return taskq.Item{}, ctx.Err()
+	}
+}
+
+// notifierQueue calls registered func on every externally added task
+type notifierQueue struct {
+	*taskq.ConcurrentQueue
+	lock   sync.Mutex
+	notify func()
+}
+
+func (q *notifierQueue) Notify(f func()) {
+	q.lock.Lock()
+	q.notify = f
+	q.lock.Unlock()
+}
+
+func (q *notifierQueue) push(t taskq.Task) {
+	q.ConcurrentQueue.Enqueue(context.Background(), t)
+	q.lock.Lock()
+	notify := q.notify
+	q.lock.Unlock()
+	notify()
+}
+
+func TestBlockingQueue_ExternalTasks(t *testing.T) {
+	q := &blockingQueue{ch: make(chan taskq.Task, 10)}
+	tq := taskq.NewWithQueue(2, q)
+	tq.Start()
+	var done int32
+	task := taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&done, 1)
+		return nil
+	})
+	waitFor(t, func() bool { return atomic.LoadInt32(&q.waits) > 0 })
+	for i := 0; i < 5; i++ {
+		q.ch <- task
+		time.Sleep(time.Millisecond)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 5 })
+	// Shutdown interrupts waiting worker
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := tq.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockingQueue_NotifyInterruptsWaiting(t *testing.T) {
+	q := &blockingQueue{ch: make(chan taskq.Task, 10)}
+	tq := taskq.NewWithQueue(1, q)
+	tq.Start()
+	defer tq.Close()
+	waitFor(t, func() bool { return atomic.LoadInt32(&q.waits) > 0 })
+	// retried task is not in queue, the only worker must stop waiting for it
+	tq.RetryPolicy = taskq.ConstantBackoff(2, 10*time.Millisecond)
+	var attempts int32
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			return errors.New("test")
+		}
+		return nil
+	}))
+	waitFor(t, func() bool { return atomic.LoadInt32(&attempts) == 2 })
+}
+
+func TestNotifier_ExternalTasks(t *testing.T) {
+	q := &notifierQueue{ConcurrentQueue: taskq.NewConcurrentQueue()}
+	tq := taskq.NewWithQueue(2, q)
+	tq.Start()
+	defer tq.Close()
+	var done int32
+	time.Sleep(10 * time.Millisecond) // workers exited on empty queue
+	for i := 0; i < 3; i++ {
+		q.push(taskq.TaskFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&done, 1)
+			return nil
+		}))
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 3 })
+}
+
+// blockingAckQueue takes tasks with delivery, DequeueWait must not be used
+type blockingAckQueue struct {
+	*taskq.ConcurrentQueue
+	waits int32
+}
+
+func (q *blockingAckQueue) DequeueWait(ctx context.Context) (taskq.Item, error) {
+	atomic.AddInt32(&q.waits, 1)
+	for {
+		t, err := q.ConcurrentQueue.Dequeue(ctx)
+		if err != taskq.EmptyQueue {
+			return taskq.Item{Task: t}, err
+		}
+		select {
+		case <-ctx.Done():
+			return taskq.Item{}, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestBlockingQueue_AckQueueTakesDelivery(t *testing.T) {
+	q := &blockingAckQueue{ConcurrentQueue: taskq.NewConcurrentQueue()}
+	tq := taskq.NewWithQueue(2, q)
+	tq.Start()
+	time.Sleep(10 * time.Millisecond) // idle workers don't wait in queue
+	var done int32
+	for i := 0; i < 5; i++ {
+		tq.Enqueue(context.Background(), countTask(&done))
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&done); n != 5 {
+		t.Fatalf("invalid executed tasks. expected=5 got=%d", n)
+	}
+	if n := atomic.LoadInt32(&q.waits); n != 0 {
+		t.Fatalf("DequeueWait was used for AckQueue: %d", n)
+	}
+	if n := q.Unacked(); n != 0 {
+		t.Fatalf("deliveries weren't acknowledged: %d", n)
+	}
+}
@@ -0,0 +1,106 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrTaskCancelled is passed to TaskOnError of a task cancelled by TaskQ.Cancel
+	// and is a cause of canceled context of running task
+	ErrTaskCancelled = errors.New("task cancelled")
+	// ErrCancelNotSupported is returned by TaskQ.Cancel for not started task when queue doesn't implement RemoveQueue
+	ErrCancelNotSupported = errors.New("queue doesn't support cancel")
+)
+
+// RemoveQueue is an optional Queue extension required for cancelling not started tasks
+type RemoveQueue interface {
+	// Remove takes task with given ID from queue. Returns ErrTaskNotFound if task is not in queue
+	Remove(ctx context.Context, id int64) (Item, error)
+}
+
+// runningJobs keeps cancel funcs of running tasks
+type runningJobs struct {
+	lock sync.Mutex
+	jobs map[int64]func(error)
+}
+
+func (r *runningJobs) add(id int64, cancel func(error)) {
+	r.lock.Lock()
+	if r.jobs == nil {
+		r.jobs = make(map[int64]func(error))
+	}
+	r.jobs[id] = cancel
+	r.lock.Unlock()
+}
+
+func (r *runningJobs) remove(id int64) {
+	r.lock.Lock()
+	delete(r.jobs, id)
+	r.lock.Unlock()
+}
+
+func (r *runningJobs) cancel(id int64, err error) bool {
+	r.lock.Lock()
+	cancel, ok := r.jobs[id]
+	r.lock.Unlock()
+	if ok {
+		cancel(err)
+	}
+	return ok
+}
+
+// Cancel aborts task by ID returned from Enqueue. Not started task is removed from queue
+// and receives ErrTaskCancelled in OnError. Context of running task is canceled with ErrTaskCancelled cause,
+// task receives ErrTaskCancelled in OnError if it returns an error
+func (t *TaskQ) Cancel(ctx context.Context, id int64) error {
+	if t.running.cancel(id, ErrTaskCancelled) {
+		return nil
+	}
+	// task is waiting for retry, rate limit tokens or partition
+	j := t.ready.remove(id)
+	if j == nil {
+		j = t.delayed.remove(id)
+	}
+	if j == nil {
+		j = t.throttled.remove(id)
+	}
+	if j == nil {
+		j = t.partitions.remove(id)
+	}
+	if j != nil {
+		atomic.AddInt32(&t.pending, -1)
+		t.cancelled(j)
+		return nil
+	}
+	q, ok := t.queue.(RemoveQueue)
+	if !ok {
+		return ErrCancelNotSupported
+	}
+	it, err := q.Remove(ctx, id)
+	if err != nil {
+		return err
+	}
+	t.dequeued()
+	j = &job{Item: it, attempt: 1}
+	// retry returned into AckQueue
+	if r := t.requeued.remove(id); r != nil {
+		atomic.AddInt32(&t.pending, -1)
+		j.attempt = r.attempt
+	}
+	t.cancelled(j)
+	return nil
+}
+
+// cancelled reports not started job cancelled by Cancel
+func (t *TaskQ) cancelled(j *job) {
+	t.counters.add(ErrTaskCancelled)
+	t.setStatus(j, StateCancelled, ErrTaskCancelled)
+	ctx, cancel, _ := t.taskContext(j)
+	defer cancel(nil)
+	taskOnError(ctx, j.Task, ErrTaskCancelled)
+	t.settle(j, ErrTaskCancelled)
+	t.leavePartition(j)
+}
@@ -0,0 +1,90 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestCancel_Pending(t *testing.T) {
+	tq := taskq.New(1)
+	var runs int32
+	var onError error
+	task := &testTask{
+		fdo: func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+		fOnError: func(ctx context.Context, err error) { onError = err },
+	}
+	id, _ := tq.Enqueue(context.Background(), task)
+	if err := tq.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if onError != taskq.ErrTaskCancelled {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrTaskCancelled, onError)
+	}
+	if s := taskState(t, tq, id); s != taskq.StateCancelled {
+		t.Fatalf("invalid state. expected=%s got=%s", taskq.StateCancelled, s)
+	}
+	tq.Start()
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 0 {
+		t.Fatal("cancelled task was executed")
+	}
+	if err := tq.Cancel(context.Background(), id); err != taskq.ErrTaskNotFound {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrTaskNotFound, err)
+	}
+}
+
+func TestCancel_Running(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	started := make(chan struct{})
+	var cause, onError atomic.Value
+	task := &testTask{
+		fdo: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			cause.Store(taskq.Cause(ctx))
+			return ctx.Err()
+		},
+		fOnError: func(ctx context.Context, err error) { onError.Store(err) },
+	}
+	id, _ := tq.Enqueue(context.Background(), task)
+	<-started
+	if err := tq.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return onError.Load() != nil })
+	if onError.Load() != taskq.ErrTaskCancelled || cause.Load() != taskq.ErrTaskCancelled {
+		t.Fatalf("invalid cancellation. err=%v cause=%v", onError.Load(), cause.Load())
+	}
+	tq.Close()
+	if s := tq.Stats(); s.Cancelled != 1 || s.Failed != 0 {
+		t.Fatalf("invalid stats. cancelled=%d failed=%d", s.Cancelled, s.Failed)
+	}
+}
+
+func TestCancel_Retrying(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ConstantBackoff(2, time.Hour)
+	tq.Start()
+	defer tq.Close()
+	id, _ := tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		return errors.New("test")
+	}))
+	waitFor(t, func() bool { return taskState(t, tq, id) == taskq.StateRetrying })
+	if err := tq.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	if s := taskState(t, tq, id); s != taskq.StateCancelled {
+		t.Fatalf("invalid state. expected=%s got=%s", taskq.StateCancelled, s)
+	}
+}
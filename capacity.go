@@ -0,0 +1,169 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when queue reached TaskQ.Capacity
+	ErrQueueFull = errors.New("queue is full")
+	// ErrTaskDropped is passed to TaskOnError of a task removed from queue by overflow policy
+	ErrTaskDropped = errors.New("task dropped")
+	// ErrCapacityNotSupported is returned by Enqueue when TaskQ.Capacity is set and queue doesn't implement SizedQueue
+	ErrCapacityNotSupported = errors.New("queue doesn't support capacity")
+	// errCallerRuns signals Enqueue to execute task in the caller goroutine
+	errCallerRuns = errors.New("caller runs")
+)
+
+// OverflowPolicy decides what to do with a new task when queue reached TaskQ.Capacity
+type OverflowPolicy int
+
+const (
+	// OverflowReject returns ErrQueueFull
+	OverflowReject OverflowPolicy = iota
+	// OverflowBlock waits for free space in queue until Enqueue context is done
+	OverflowBlock
+	// OverflowDropOldest removes the oldest task from queue. Queue must implement DropQueue
+	OverflowDropOldest
+	// OverflowDropLowestPriority removes the task with the lowest priority from queue. Queue must implement DropQueue
+	OverflowDropLowestPriority
+	// OverflowCallerRuns executes task in the goroutine which called Enqueue.
+	// Caller waits for rate limit tokens, task of busy TaskPartition is rejected with ErrQueueFull.
+	// EnqueueAt can't run task before it's due, so scheduled task is rejected with ErrQueueFull
+	OverflowCallerRuns
+)
+
+// SizedQueue is an optional Queue extension required by TaskQ.Capacity
+type SizedQueue interface {
+	Len(context.Context) int
+}
+
+// DropQueue is an optional Queue extension required by drop overflow policies
+type DropQueue interface {
+	// DropOldest removes the task which is waiting longest. Returns EmptyQueue if nothing to drop
+	DropOldest(context.Context) (Item, error)
+	// DropLowestPriority removes the task with the lowest priority. Returns EmptyQueue if nothing to drop
+	DropLowestPriority(context.Context) (Item, error)
+}
+
+// spaceSignal notifies blocked Enqueue calls about dequeued tasks
+type spaceSignal struct {
+	lock sync.Mutex
+	ch   chan struct{}
+}
+
+func (s *spaceSignal) wait() <-chan struct{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+func (s *spaceSignal) broadcast() {
+	s.lock.Lock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+	s.lock.Unlock()
+}
+
+// push adds task into queue using `enqueue` func with respect to TaskQ.Capacity
+func (t *TaskQ) push(ctx context.Context, enqueue func() (int64, error)) (int64, error) {
+	if t.Capacity <= 0 {
+		return enqueue()
+	}
+	q, ok := t.queue.(SizedQueue)
+	if !ok {
+		return -1, ErrCapacityNotSupported
+	}
+	for {
+		space := t.space.wait()
+		t.enqueueLock.Lock()
+		if q.Len(ctx) < t.Capacity {
+			id, err := enqueue()
+			t.enqueueLock.Unlock()
+			return id, err
+		}
+		switch t.OverflowPolicy {
+		case OverflowBlock:
+			t.enqueueLock.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				return -1, ctx.Err()
+			}
+		case OverflowDropOldest, OverflowDropLowestPriority:
+			dropped, err := t.drop(ctx)
+			if err != nil {
+				t.enqueueLock.Unlock()
+				return -1, err
+			}
+			id, err := enqueue()
+			t.enqueueLock.Unlock()
+			t.dropped(dropped, ErrTaskDropped)
+			return id, err
+		case OverflowCallerRuns:
+			t.enqueueLock.Unlock()
+			return -1, errCallerRuns
+		default:
+			t.enqueueLock.Unlock()
+			return -1, ErrQueueFull
+		}
+	}
+}
+
+func (t *TaskQ) drop(ctx context.Context) (Item, error) {
+	q, ok := t.queue.(DropQueue)
+	if !ok {
+		return Item{}, ErrQueueFull
+	}
+	var it Item
+	var err error
+	if t.OverflowPolicy == OverflowDropOldest {
+		it, err = q.DropOldest(ctx)
+	} else {
+		it, err = q.DropLowestPriority(ctx)
+	}
+	if err == EmptyQueue {
+		return Item{}, ErrQueueFull
+	}
+	return it, err
+}
+
+// dropped reports removed from queue task
+func (t *TaskQ) dropped(it Item, err error) {
+	atomic.AddInt64(&t.counters.dropped, 1)
+	j := &job{Item: it, attempt: 1}
+	t.setStatus(j, StateCancelled, err)
+	ctx, cancel, _ := t.taskContext(j)
+	defer cancel(nil)
+	taskOnError(ctx, it.Task, err)
+}
+
+// callerRuns executes task in Enqueue caller goroutine. Task of busy partition is rejected with ErrQueueFull
+// for keeping partition order, caller waits for rate limit tokens
+func (t *TaskQ) callerRuns(ctx context.Context, task Task) error {
+	j := &job{Item: Item{Task: task, Ctx: ctx}, attempt: 1}
+	if key := partitionKey(task); key != "" && !t.partitions.enter(key, j, false) {
+		return ErrQueueFull
+	}
+	if delay := t.reserve(j); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			t.leavePartition(j)
+			return ctx.Err()
+		}
+	}
+	t.runJob(j)
+	return nil
+}
@@ -0,0 +1,128 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func noopTask() taskq.Task {
+	return taskq.TaskFunc(func(ctx context.Context) error { return nil })
+}
+
+func TestCapacityReject_Err(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 2
+	for i := 0; i < 2; i++ {
+		if _, err := tq.Enqueue(context.Background(), noopTask()); err != nil {
+			t.Fatal("enqueue:", err)
+		}
+	}
+	if _, err := tq.Enqueue(context.Background(), noopTask()); err != taskq.ErrQueueFull {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrQueueFull, err)
+	}
+}
+
+func TestCapacityBlock_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowBlock
+	tq.Enqueue(context.Background(), noopTask())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tq.Enqueue(ctx, noopTask()); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected=%s got=%v", context.DeadlineExceeded, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tq.Start()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := tq.Enqueue(ctx, noopTask()); err != nil {
+		t.Fatal("enqueue wasn't unblocked:", err)
+	}
+}
+
+func TestCapacityDropOldest_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowDropOldest
+	rch := make(chan error, 1)
+	tq.Enqueue(context.Background(), &testTask{
+		fOnError: func(ctx context.Context, err error) {
+			rch <- err
+		},
+	})
+	if _, err := tq.Enqueue(context.Background(), noopTask()); err != nil {
+		t.Fatal("enqueue:", err)
+	}
+	select {
+	case err := <-rch:
+		if !errors.Is(err, taskq.ErrTaskDropped) {
+			t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrTaskDropped, err)
+		}
+	default:
+		t.Fatal("dropped task wasn't reported")
+	}
+	if s := tq.Stats(); s.Dropped != 1 {
+		t.Fatalf("invalid stats: %+v", s)
+	}
+}
+
+func TestCapacityDropLowestPriority_Ok(t *testing.T) {
+	q := taskq.NewPriorityQueue(0)
+	tq := taskq.NewWithQueue(1, q)
+	tq.Capacity = 2
+	tq.OverflowPolicy = taskq.OverflowDropLowestPriority
+	tq.Enqueue(context.Background(), &priorityTask{priority: 5})
+	low, _ := tq.Enqueue(context.Background(), &priorityTask{priority: 1})
+	high, _ := tq.Enqueue(context.Background(), &priorityTask{priority: 10})
+	ids := dequeueIDs(t, q, 2)
+	if ids[0] != high || ids[1] == low {
+		t.Fatalf("lowest priority task wasn't dropped. order=%v", ids)
+	}
+}
+
+func TestCapacityCallerRuns_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowCallerRuns
+	tq.Enqueue(context.Background(), noopTask())
+	var executed bool
+	_, err := tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		executed = true
+		return nil
+	}))
+	if err != nil || !executed {
+		t.Fatalf("task wasn't executed by caller. err=%v", err)
+	}
+}
+
+func TestCapacityCallerRunsScheduled_Err(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowCallerRuns
+	tq.EnqueueAfter(context.Background(), noopTask(), time.Hour)
+	var executed bool
+	_, err := tq.EnqueueAfter(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		executed = true
+		return nil
+	}), time.Hour)
+	if err != taskq.ErrQueueFull || executed {
+		t.Fatalf("scheduled task wasn't rejected. err=%v executed=%v", err, executed)
+	}
+}
+
+func TestCapacityNotSupported_Err(t *testing.T) {
+	tq := taskq.NewWithQueue(1, &testQueue{})
+	tq.Capacity = 1
+	if _, err := tq.Enqueue(context.Background(), noopTask()); err != taskq.ErrCapacityNotSupported {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrCapacityNotSupported, err)
+	}
+}
@@ -1,9 +1,105 @@
 package taskq
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCanceledBeforeStart passed to TaskOnError if enqueue context was done before task started
+var ErrCanceledBeforeStart = errors.New("task canceled before start")
 
 type ctxWaitKey struct{}
 
+type ctxCauseKey struct{}
+
 func ContextWithWait(ctx context.Context) context.Context {
 	return context.WithValue(ctx, ctxWaitKey{}, true)
 }
+
+type cancelCause struct {
+	lock     sync.Mutex
+	canceled bool
+	err      error
+	parent   context.Context
+}
+
+func (c *cancelCause) cause() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+// withCancelCause returns a child context which cancel func stores the reason of cancellation
+func withCancelCause(parent context.Context) (context.Context, func(error)) {
+	c := &cancelCause{parent: parent}
+	ctx, cancel := context.WithCancel(context.WithValue(parent, ctxCauseKey{}, c))
+	return ctx, func(cause error) {
+		c.lock.Lock()
+		if !c.canceled {
+			c.canceled = true
+			c.err = cause
+		}
+		c.lock.Unlock()
+		cancel()
+	}
+}
+
+// Cause returns the reason why task context was canceled.
+// Returns nil if context is not done and ctx.Err() if cause is unknown
+func Cause(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	c, _ := ctx.Value(ctxCauseKey{}).(*cancelCause)
+	for c != nil {
+		if err := c.cause(); err != nil {
+			return err
+		}
+		c, _ = c.parent.Value(ctxCauseKey{}).(*cancelCause)
+	}
+	return ctx.Err()
+}
+
+// valuesContext takes deadline and cancellation from embedded context
+// and falls back to values context on value lookup
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
+
+// taskContext creates an own context for every task, derived from TaskQ base context
+func (t *TaskQ) taskContext(j *job) (context.Context, func(error), error) {
+	base := context.WithValue(t.ctx, ctxAttemptKey{}, j.attempt)
+	if j.Deliveries > 0 {
+		base = context.WithValue(base, ctxDeliveriesKey{}, j.Deliveries)
+	}
+	ctx, cancel := withCancelCause(base)
+	enqueueCtx := j.Ctx
+	if enqueueCtx == nil {
+		return ctx, cancel, nil
+	}
+	ctx = valuesContext{Context: ctx, values: enqueueCtx}
+	// retry of started task is not skipped
+	if err := enqueueCtx.Err(); err != nil && j.attempt == 1 {
+		return ctx, cancel, fmt.Errorf("%w: %s", ErrCanceledBeforeStart, err)
+	}
+	if t.InheritCancel && enqueueCtx.Done() != nil {
+		go func() {
+			select {
+			case <-enqueueCtx.Done():
+				cancel(enqueueCtx.Err())
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel, nil
+}
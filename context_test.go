@@ -0,0 +1,126 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+type ctxTestKey struct{}
+
+func TestTaskContextInheritsValues(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	rch := make(chan interface{})
+	ctx := context.WithValue(context.Background(), ctxTestKey{}, "foo")
+	tq.Enqueue(ctx, taskq.TaskFunc(func(ctx context.Context) error {
+		rch <- ctx.Value(ctxTestKey{})
+		return nil
+	}))
+	select {
+	case v := <-rch:
+		if v != "foo" {
+			t.Fatalf("invalid value. expected=foo got=%v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
+
+func TestTaskContextNotCanceledByEnqueueContext(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	started, rch := make(chan struct{}), make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	tq.Enqueue(ctx, taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		time.Sleep(10 * time.Millisecond)
+		rch <- ctx.Err()
+		return nil
+	}))
+	<-started
+	cancel()
+	if err := <-rch; err != nil {
+		t.Fatalf("task context canceled. err=%s", err)
+	}
+}
+
+func TestTaskContextInheritCancel(t *testing.T) {
+	tq := taskq.New(1)
+	tq.InheritCancel = true
+	tq.Start()
+	started, rch := make(chan struct{}), make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	tq.Enqueue(ctx, taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		rch <- ctx.Err()
+		return nil
+	}))
+	<-started
+	cancel()
+	select {
+	case err := <-rch:
+		if err != context.Canceled {
+			t.Fatalf("invalid error. expected=%s got=%s", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
+
+func TestTaskSkippedOnCanceledEnqueueContext(t *testing.T) {
+	tq := taskq.New(1)
+	rch := make(chan error)
+	ctx, cancel := context.WithCancel(context.Background())
+	tq.Enqueue(ctx, &testTask{
+		fdo: func(ctx context.Context) error {
+			panic("task must be skipped")
+		},
+		fOnError: func(ctx context.Context, err error) {
+			rch <- err
+		},
+	})
+	cancel()
+	tq.Start()
+	select {
+	case err := <-rch:
+		if !errors.Is(err, taskq.ErrCanceledBeforeStart) {
+			t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrCanceledBeforeStart, err)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
+
+func TestTaskRetriedAfterEnqueueContextCanceled(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ConstantBackoff(2, time.Millisecond)
+	rch := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	tq.Enqueue(ctx, &testTask{
+		fdo: func(taskCtx context.Context) error {
+			if taskq.Attempt(taskCtx) == 1 {
+				// e.g. HTTP request finished after the task started
+				cancel()
+				return errors.New("test")
+			}
+			return nil
+		},
+		fdone:    func(ctx context.Context) { rch <- nil },
+		fOnError: func(ctx context.Context, err error) { rch <- err },
+	})
+	tq.Start()
+	defer tq.Close()
+	select {
+	case err := <-rch:
+		if err != nil {
+			t.Fatalf("retry was skipped: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
@@ -0,0 +1,225 @@
+package taskq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time after `t`. Zero time means no more activations
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// fixedRate activates every interval counting from the previous activation time
+type fixedRate time.Duration
+
+// FixedRate activates periodic task every `d` counting from the previous activation
+func FixedRate(d time.Duration) Schedule {
+	return fixedRate(d)
+}
+
+func (r fixedRate) Next(t time.Time) time.Time {
+	if r <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(r))
+}
+
+// fixedDelay activates after delay counting from the end of the previous run
+type fixedDelay time.Duration
+
+// FixedDelay activates periodic task `d` after the previous run is finished
+func FixedDelay(d time.Duration) Schedule {
+	return fixedDelay(d)
+}
+
+func (r fixedDelay) Next(t time.Time) time.Time {
+	if r <= 0 {
+		return time.Time{}
+	}
+	return t.Add(time.Duration(r))
+}
+
+// CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar or dowStar is set when field was `*`, used for day matching rules
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var (
+	cronMinute = cronField{min: 0, max: 59}
+	cronHour   = cronField{min: 0, max: 23}
+	cronDom    = cronField{min: 1, max: 31}
+	cronMonth  = cronField{min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	cronDow = cronField{min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+var errCronSyntax = errors.New("invalid cron expression")
+
+// ParseCron parses standard 5-field cron expression `minute hour day-of-month month day-of-week`
+// or one of descriptors: @yearly, @monthly, @weekly, @daily, @hourly.
+// Time zone can be set with `CRON_TZ=<zone>` prefix, default is time.Local
+func ParseCron(expr string) (*CronSchedule, error) {
+	loc := time.Local
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		i := strings.IndexByte(expr, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q", errCronSyntax, expr)
+		}
+		var err error
+		loc, err = time.LoadLocation(expr[strings.IndexByte(expr, '=')+1 : i])
+		if err != nil {
+			return nil, err
+		}
+		expr = strings.TrimSpace(expr[i:])
+	}
+	return ParseCronInLocation(expr, loc)
+}
+
+// ParseCronInLocation parses cron expression which activation times are calculated in `loc` time zone
+func ParseCronInLocation(expr string, loc *time.Location) (*CronSchedule, error) {
+	if d, ok := cronDescriptors[expr]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q expected 5 fields", errCronSyntax, expr)
+	}
+	s := &CronSchedule{loc: loc}
+	var err error
+	for i, f := range []struct {
+		dst   *uint64
+		field cronField
+	}{
+		{&s.minute, cronMinute},
+		{&s.hour, cronHour},
+		{&s.dom, cronDom},
+		{&s.month, cronMonth},
+		{&s.dow, cronDow},
+	} {
+		if *f.dst, err = f.field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("%w: %q: %s", errCronSyntax, expr, err)
+		}
+	}
+	// 7 is an alias of sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:i]
+		}
+		lo, hi := f.min, f.max
+		if part != "*" && part != "?" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q out of range [%d, %d]", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the next activation time after `t`. Returns zero time if there is no activation in 5 years
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	t = t.In(s.loc).Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			if !next.After(t) { // DST transition
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.In(origLoc)
+	}
+	return time.Time{}
+}
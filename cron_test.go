@@ -0,0 +1,52 @@
+package taskq_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 30, 15, 0, time.UTC)
+	for _, tc := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.January, 31, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2024, time.February, 1, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := taskq.ParseCronInLocation(tc.expr, time.UTC)
+		if err != nil {
+			t.Fatalf("parse %q: %s", tc.expr, err)
+		}
+		if next := s.Next(from); !next.Equal(tc.expected) {
+			t.Fatalf("invalid next activation of %q. expected=%s got=%s", tc.expr, tc.expected, next)
+		}
+	}
+}
+
+func TestCronTimeZone(t *testing.T) {
+	s, err := taskq.ParseCron("CRON_TZ=Asia/Tokyo 0 9 * * *")
+	if err != nil {
+		t.Skip("time zone database is not available:", err)
+	}
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC) // 09:00 JST
+	if next := s.Next(from); !next.Equal(expected) {
+		t.Fatalf("invalid next activation. expected=%s got=%s", expected, next)
+	}
+}
+
+func TestCronInvalidExpression_Err(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "CRON_TZ=Nowhere/Unknown * * * * *"} {
+		if _, err := taskq.ParseCron(expr); err == nil {
+			t.Fatalf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMaxDeliveries is passed to TaskOnError of a task delivered more than TaskQ.MaxDeliveries times
+var ErrMaxDeliveries = errors.New("max deliveries exceeded")
+
+// DeadTask is a failed task with failure metadata which is moved to TaskQ.DeadLetter queue
+type DeadTask struct {
+	Task
+	// ID of task in dead-letter queue
+	ID int64
+	// TaskID is an ID of task in the original queue
+	TaskID     int64
+	Err        error
+	Attempts   int
+	Deliveries int
+	EnqueuedAt time.Time
+	FailedAt   time.Time
+}
+
+func (d *DeadTask) Unwrap() Task {
+	return d.Task
+}
+
+// deadLetter moves failed job to TaskQ.DeadLetter queue. Returns false if job wasn't moved
+func (t *TaskQ) deadLetter(j *job, err error) bool {
+	if t.DeadLetter == nil {
+		return false
+	}
+	d := &DeadTask{
+		Task:       j.Task,
+		TaskID:     j.ID,
+		Err:        err,
+		Attempts:   j.attempt,
+		Deliveries: j.Deliveries,
+		EnqueuedAt: j.EnqueuedAt,
+		FailedAt:   time.Now(),
+	}
+	atomic.AddInt64(&t.counters.deadLettered, 1)
+	if _, err := t.DeadLetter.Enqueue(context.Background(), d); err != nil {
+		atomic.AddInt64(&t.counters.deadLettered, -1)
+		if t.OnDeadLetterError != nil {
+			t.OnDeadLetterError(context.Background(), j.ID, err)
+		}
+		return false
+	}
+	return true
+}
+
+// DeadLetterQueue is an in-memory dead-letter queue with API for inspecting and replaying failed tasks
+type DeadLetterQueue struct {
+	lock    sync.Mutex
+	lastInc int64
+	tasks   []*DeadTask
+}
+
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{}
+}
+
+// Enqueue adds dead task. Task which is not a DeadTask is wrapped into it
+func (q *DeadLetterQueue) Enqueue(_ context.Context, t Task) (int64, error) {
+	d, ok := t.(*DeadTask)
+	if !ok {
+		d = &DeadTask{Task: t, FailedAt: time.Now()}
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.lastInc++
+	d.ID = q.lastInc
+	q.tasks = append(q.tasks, d)
+	return d.ID, nil
+}
+
+// Dequeue removes the oldest dead task
+func (q *DeadLetterQueue) Dequeue(_ context.Context) (Task, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.tasks) == 0 {
+		return nil, EmptyQueue
+	}
+	d := q.tasks[0]
+	q.tasks[0] = nil
+	q.tasks = q.tasks[1:]
+	return d, nil
+}
+
+func (q *DeadLetterQueue) Len(_ context.Context) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.tasks)
+}
+
+// List returns dead tasks from the oldest one
+func (q *DeadLetterQueue) List() []*DeadTask {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return append([]*DeadTask(nil), q.tasks...)
+}
+
+// Get returns dead task by its ID in dead-letter queue
+func (q *DeadLetterQueue) Get(id int64) (*DeadTask, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, d := range q.tasks {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// Purge removes dead tasks with given IDs, all tasks if no IDs given. Returns the number of removed tasks
+func (q *DeadLetterQueue) Purge(ids ...int64) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.take(ids))
+}
+
+// Requeue enqueues dead tasks with given IDs into TaskQ, all tasks if no IDs given.
+// Task stays in dead-letter queue if Enqueue failed. Returns the number of enqueued tasks
+func (q *DeadLetterQueue) Requeue(ctx context.Context, tq *TaskQ, ids ...int64) (int, error) {
+	q.lock.Lock()
+	tasks := q.take(ids)
+	q.lock.Unlock()
+	for i, d := range tasks {
+		if _, err := tq.Enqueue(ctx, d.Task); err != nil {
+			q.lock.Lock()
+			q.tasks = append(tasks[i:], q.tasks...)
+			q.lock.Unlock()
+			return i, err
+		}
+	}
+	return len(tasks), nil
+}
+
+// take removes tasks with given IDs, all tasks if no IDs given. Must be called under lock
+func (q *DeadLetterQueue) take(ids []int64) []*DeadTask {
+	if len(ids) == 0 {
+		tasks := q.tasks
+		q.tasks = nil
+		return tasks
+	}
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	var taken []*DeadTask
+	tasks := q.tasks[:0]
+	for _, d := range q.tasks {
+		if remove[d.ID] {
+			taken = append(taken, d)
+		} else {
+			tasks = append(tasks, d)
+		}
+	}
+	for i := len(tasks); i < len(q.tasks); i++ {
+		q.tasks[i] = nil
+	}
+	q.tasks = tasks
+	return taken
+}
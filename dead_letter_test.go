@@ -0,0 +1,118 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestDeadLetter_RetriesExhausted(t *testing.T) {
+	dlq := taskq.NewDeadLetterQueue()
+	tq := taskq.New(1)
+	tq.DeadLetter = dlq
+	tq.RetryPolicy = taskq.ConstantBackoff(2, time.Millisecond)
+	tq.Start()
+	var fixed, done int32
+	testErr := errors.New("test")
+	id, _ := tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		if atomic.LoadInt32(&fixed) == 0 {
+			return testErr
+		}
+		atomic.AddInt32(&done, 1)
+		return nil
+	}))
+	waitFor(t, func() bool { return dlq.Len(context.Background()) == 1 })
+	d := dlq.List()[0]
+	if d.TaskID != id || d.Err != testErr || d.Attempts != 2 || d.FailedAt.IsZero() {
+		t.Fatalf("invalid dead task: %+v", d)
+	}
+	if s := tq.Stats(); s.DeadLettered != 1 {
+		t.Fatalf("invalid dead lettered. expected=1 got=%d", s.DeadLettered)
+	}
+	// replay after fix
+	atomic.StoreInt32(&fixed, 1)
+	if n, err := dlq.Requeue(context.Background(), tq, d.ID); err != nil || n != 1 {
+		t.Fatalf("requeue failed. n=%d err=%v", n, err)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 1 })
+	if dlq.Len(context.Background()) != 0 {
+		t.Fatal("requeued task wasn't removed from dead-letter queue")
+	}
+	tq.Close()
+}
+
+func TestDeadLetter_MaxDeliveries(t *testing.T) {
+	ctx := context.Background()
+	q := taskq.NewConcurrentQueue()
+	var runs int32
+	q.Enqueue(ctx, taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}))
+	// task was delivered to consumers which crashed
+	for i := 0; i < 2; i++ {
+		d, _ := q.DequeueDelivery(ctx)
+		d.Nack(ctx, 0)
+	}
+	dlq := taskq.NewDeadLetterQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.DeadLetter = dlq
+	tq.MaxDeliveries = 2
+	tq.Start()
+	waitFor(t, func() bool { return dlq.Len(ctx) == 1 })
+	tq.Shutdown(taskq.ContextWithWait(ctx))
+	d := dlq.List()[0]
+	if runs != 0 || d.Err != taskq.ErrMaxDeliveries || d.Deliveries != 3 {
+		t.Fatalf("invalid state. runs=%d err=%v deliveries=%d", runs, d.Err, d.Deliveries)
+	}
+	if q.Unacked() != 0 || q.Len(ctx) != 0 {
+		t.Fatalf("quarantined task wasn't acknowledged. unacked=%d len=%d", q.Unacked(), q.Len(ctx))
+	}
+}
+
+func TestDeadLetterQueue_Purge(t *testing.T) {
+	ctx := context.Background()
+	dlq := taskq.NewDeadLetterQueue()
+	for i := 0; i < 3; i++ {
+		dlq.Enqueue(ctx, noopTask())
+	}
+	if _, ok := dlq.Get(2); !ok {
+		t.Fatal("dead task not found")
+	}
+	if n := dlq.Purge(2); n != 1 {
+		t.Fatalf("invalid purged. expected=1 got=%d", n)
+	}
+	if _, ok := dlq.Get(2); ok {
+		t.Fatal("purged task found")
+	}
+	if n := dlq.Purge(); n != 2 || dlq.Len(ctx) != 0 {
+		t.Fatalf("invalid purge all. purged=%d len=%d", n, dlq.Len(ctx))
+	}
+}
+
+func TestDeadLetter_MaxDeliveriesIgnoresRetries(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	dlq := taskq.NewDeadLetterQueue()
+	tq := taskq.NewWithQueue(1, q)
+	tq.DeadLetter = dlq
+	tq.MaxDeliveries = 2
+	tq.RetryPolicy = taskq.ConstantBackoff(5, time.Millisecond)
+	tq.Start()
+	var runs int32
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) < 5 {
+			return errors.New("test")
+		}
+		return nil
+	}))
+	waitFor(t, func() bool { return atomic.LoadInt32(&runs) == 5 })
+	tq.Shutdown(taskq.ContextWithWait(context.Background()))
+	if dlq.Len(context.Background()) != 0 || q.Len(context.Background()) != 0 || q.Unacked() != 0 {
+		t.Fatalf("retried task was quarantined. dead=%d len=%d unacked=%d",
+			dlq.Len(context.Background()), q.Len(context.Background()), q.Unacked())
+	}
+}
@@ -0,0 +1,115 @@
+package taskq
+
+import (
+	"context"
+	"sync"
+)
+
+// TaskValue is an optional Task extension for tasks producing a value.
+// Value is called after successful Do
+type TaskValue interface {
+	Value() interface{}
+}
+
+// ValueFunc is a Task producing a value for Future
+type ValueFunc func(ctx context.Context) (interface{}, error)
+
+func (f ValueFunc) Do(ctx context.Context) error {
+	_, err := f(ctx)
+	return err
+}
+
+// Future is a handle of enqueued task for waiting its result
+type Future struct {
+	ID int64
+
+	once  sync.Once
+	done  chan struct{}
+	err   error
+	value interface{}
+}
+
+func (f *Future) resolve(value interface{}, err error) {
+	f.once.Do(func() {
+		f.value, f.err = value, err
+		close(f.done)
+	})
+}
+
+// Done is closed when task is finished
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for task and returns its error. Returns ctx error if ctx is done first
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Value returns value of finished task produced by ValueFunc or TaskValue. Nil if task is not finished or failed
+func (f *Future) Value() interface{} {
+	select {
+	case <-f.done:
+		return f.value
+	default:
+		return nil
+	}
+}
+
+// EnqueueFuture enqueues task and returns Future which is resolved when task is finished,
+// including failure after all retries and drop by overflow policy
+func (t *TaskQ) EnqueueFuture(ctx context.Context, task Task) (*Future, error) {
+	if task == nil {
+		return nil, ErrNilTask
+	}
+	f := &Future{done: make(chan struct{})}
+	id, err := t.Enqueue(ctx, &futureTask{task: task, f: f})
+	if err != nil {
+		return nil, err
+	}
+	f.ID = id
+	return f, nil
+}
+
+// futureTask resolves Future on task events
+type futureTask struct {
+	task  Task
+	f     *Future
+	value interface{}
+}
+
+func (t *futureTask) Unwrap() Task {
+	return t.task
+}
+
+func (t *futureTask) Do(ctx context.Context) error {
+	if vf, ok := t.task.(ValueFunc); ok {
+		var err error
+		t.value, err = vf(ctx)
+		return err
+	}
+	if err := t.task.Do(ctx); err != nil {
+		return err
+	}
+	if tv, ok := t.task.(TaskValue); ok && tv != nil {
+		t.value = tv.Value()
+	}
+	return nil
+}
+
+func (t *futureTask) Done(ctx context.Context) {
+	if event, ok := t.task.(TaskDone); ok && event != nil {
+		event.Done(ctx)
+	}
+	t.f.resolve(t.value, nil)
+}
+
+func (t *futureTask) OnError(ctx context.Context, err error) {
+	taskOnError(ctx, t.task, err)
+	t.f.resolve(nil, err)
+}
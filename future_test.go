@@ -0,0 +1,74 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestEnqueueFuture_Value(t *testing.T) {
+	tq := taskq.New(2)
+	tq.Start()
+	defer tq.Close()
+	futures := make([]*taskq.Future, 3)
+	for i := range futures {
+		i := i
+		f, err := tq.EnqueueFuture(context.Background(), taskq.ValueFunc(func(ctx context.Context) (interface{}, error) {
+			return i * 10, nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures[i] = f
+	}
+	for i, f := range futures {
+		if err := f.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if v := f.Value(); v != i*10 {
+			t.Fatalf("invalid value. expected=%d got=%v", i*10, v)
+		}
+	}
+}
+
+func TestEnqueueFuture_Error(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ConstantBackoff(2, time.Millisecond)
+	tq.Start()
+	defer tq.Close()
+	testErr := errors.New("test")
+	var onError error
+	task := &testTask{resultErr: testErr, fOnError: func(ctx context.Context, err error) { onError = err }}
+	f, err := tq.EnqueueFuture(context.Background(), task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-f.Done()
+	if err := f.Wait(context.Background()); err != testErr {
+		t.Fatalf("invalid error. expected=%s got=%v", testErr, err)
+	}
+	// task events are still invoked
+	if onError != testErr {
+		t.Fatalf("OnError wasn't called. err=%v", onError)
+	}
+}
+
+func TestFutureWait_ContextDone(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	release := make(chan struct{})
+	defer close(release)
+	f, _ := tq.EnqueueFuture(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected=%s got=%v", context.DeadlineExceeded, err)
+	}
+}
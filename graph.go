@@ -0,0 +1,316 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrGraphCycle     = errors.New("graph has a cycle")
+	ErrDuplicateNode  = errors.New("duplicate graph node")
+	ErrUnknownNode    = errors.New("unknown graph node")
+	ErrGraphSubmitted = errors.New("graph submitted")
+	// ErrDependencyFailed is a result of node skipped because of failed dependency
+	ErrDependencyFailed = errors.New("dependency failed")
+)
+
+// GraphFailurePolicy decides what happens with graph nodes when a node fails
+type GraphFailurePolicy int
+
+const (
+	// GraphSkipDependents skips nodes depending on failed node, other nodes are executed
+	GraphSkipDependents GraphFailurePolicy = iota
+	// GraphCancelAll skips all not started nodes and cancels running ones
+	GraphCancelAll
+)
+
+// NodeResult is a result of graph node. Skipped node has StateCancelled and ErrDependencyFailed
+type NodeResult struct {
+	State TaskState
+	Err   error
+	// Value is produced by ValueFunc or TaskValue
+	Value interface{}
+}
+
+type graphNode struct {
+	name       string
+	task       Task
+	deps       []string
+	dependents []*graphNode
+	// number of not finished dependencies
+	waiting int
+	future  *Future
+	started bool
+	done    bool
+	result  NodeResult
+}
+
+// Graph runs tasks on TaskQ according to their dependencies.
+// Node is enqueued as soon as all its dependencies succeeded
+type Graph struct {
+	tq        *TaskQ
+	OnFailure GraphFailurePolicy
+
+	lock      sync.Mutex
+	nodes     map[string]*graphNode
+	order     []string
+	ctx       context.Context
+	remaining int
+	err       error
+	// cancelled is set by GraphCancelAll policy on failure
+	cancelled bool
+	done      chan struct{}
+}
+
+func NewGraph(tq *TaskQ) *Graph {
+	return &Graph{
+		tq:    tq,
+		nodes: make(map[string]*graphNode),
+		done:  make(chan struct{}),
+	}
+}
+
+// Add declares node `name` running task after all `deps` succeeded. Dependencies may be added later
+func (g *Graph) Add(name string, task Task, deps ...string) error {
+	if task == nil {
+		return ErrNilTask
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.ctx != nil {
+		return ErrGraphSubmitted
+	}
+	if _, ok := g.nodes[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateNode, name)
+	}
+	g.nodes[name] = &graphNode{name: name, task: task, deps: deps}
+	g.order = append(g.order, name)
+	return nil
+}
+
+// Submit validates graph and enqueues nodes without dependencies
+func (g *Graph) Submit(ctx context.Context) error {
+	g.lock.Lock()
+	if g.ctx != nil {
+		g.lock.Unlock()
+		return ErrGraphSubmitted
+	}
+	if err := g.link(); err != nil {
+		g.lock.Unlock()
+		return err
+	}
+	g.ctx = ctx
+	g.remaining = len(g.nodes)
+	var ready []*graphNode
+	for _, name := range g.order {
+		if n := g.nodes[name]; n.waiting == 0 {
+			ready = append(ready, n)
+		}
+	}
+	if g.remaining == 0 {
+		close(g.done)
+	}
+	g.lock.Unlock()
+	g.enqueue(ready)
+	return nil
+}
+
+// link resolves dependencies and checks graph for cycles. Must be called under lock
+func (g *Graph) link() error {
+	for _, name := range g.order {
+		n := g.nodes[name]
+		n.waiting = len(n.deps)
+		n.dependents = nil
+	}
+	for _, name := range g.order {
+		n := g.nodes[name]
+		for _, dep := range n.deps {
+			d, ok := g.nodes[dep]
+			if !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrUnknownNode, name, dep)
+			}
+			d.dependents = append(d.dependents, n)
+		}
+	}
+	// Kahn's algorithm: nodes left unvisited are in cycle
+	waiting := make(map[*graphNode]int, len(g.nodes))
+	var queue []*graphNode
+	for _, name := range g.order {
+		n := g.nodes[name]
+		waiting[n] = n.waiting
+		if n.waiting == 0 {
+			queue = append(queue, n)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, d := range n.dependents {
+			if waiting[d]--; waiting[d] == 0 {
+				queue = append(queue, d)
+			}
+		}
+	}
+	if visited == len(g.nodes) {
+		return nil
+	}
+	var cycle []string
+	for n, w := range waiting {
+		if w > 0 {
+			cycle = append(cycle, n.name)
+		}
+	}
+	sort.Strings(cycle)
+	return fmt.Errorf("%w: %s", ErrGraphCycle, strings.Join(cycle, ", "))
+}
+
+func (g *Graph) enqueue(nodes []*graphNode) {
+	for _, n := range nodes {
+		g.lock.Lock()
+		if n.done {
+			g.lock.Unlock()
+			continue
+		}
+		n.started = true
+		g.lock.Unlock()
+		f, err := g.tq.EnqueueFuture(g.ctx, n.task)
+		if err != nil {
+			g.finish(n, nil, err)
+			continue
+		}
+		g.lock.Lock()
+		n.future = f
+		cancelled := g.cancelled
+		g.lock.Unlock()
+		if cancelled {
+			g.tq.Cancel(context.Background(), f.ID)
+		}
+		go func(n *graphNode) {
+			<-f.Done()
+			g.finish(n, f.value, f.err)
+		}(n)
+	}
+}
+
+func (g *Graph) finish(n *graphNode, value interface{}, err error) {
+	var ready []*graphNode
+	var cancel []*Future
+	g.lock.Lock()
+	if n.done {
+		g.lock.Unlock()
+		return
+	}
+	state := StateSucceeded
+	switch {
+	case err == ErrTaskCancelled:
+		state = StateCancelled
+	case err != nil:
+		state = StateFailed
+	}
+	g.complete(n, NodeResult{State: state, Err: err, Value: value})
+	switch {
+	case err == nil:
+		for _, d := range n.dependents {
+			if d.waiting--; d.waiting == 0 && !d.done {
+				ready = append(ready, d)
+			}
+		}
+	case g.OnFailure == GraphCancelAll:
+		if g.err == nil {
+			g.err = fmt.Errorf("%s: %w", n.name, err)
+		}
+		g.cancelled = true
+		for _, name := range g.order {
+			d := g.nodes[name]
+			switch {
+			case d.done:
+			case d.future != nil:
+				cancel = append(cancel, d.future)
+			case !d.started:
+				g.complete(d, NodeResult{State: StateCancelled, Err: ErrDependencyFailed})
+			}
+		}
+	default:
+		if g.err == nil {
+			g.err = fmt.Errorf("%s: %w", n.name, err)
+		}
+		g.skip(n)
+	}
+	g.lock.Unlock()
+
+	g.enqueue(ready)
+	for _, f := range cancel {
+		g.tq.Cancel(context.Background(), f.ID)
+	}
+}
+
+// skip marks all not finished dependents of node as skipped. Must be called under lock
+func (g *Graph) skip(n *graphNode) {
+	for _, d := range n.dependents {
+		if !d.done {
+			g.complete(d, NodeResult{State: StateCancelled, Err: ErrDependencyFailed})
+			g.skip(d)
+		}
+	}
+}
+
+// complete sets result of node. Must be called under lock
+func (g *Graph) complete(n *graphNode, result NodeResult) {
+	n.done = true
+	n.result = result
+	g.remaining--
+	if g.remaining == 0 {
+		close(g.done)
+	}
+}
+
+// Done is closed when all nodes are finished or skipped
+func (g *Graph) Done() <-chan struct{} {
+	return g.done
+}
+
+// Wait waits for all nodes and returns their results with the first node error.
+// Returns ctx error if ctx is done first
+func (g *Graph) Wait(ctx context.Context) (map[string]NodeResult, error) {
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	results := make(map[string]NodeResult, len(g.nodes))
+	for name, n := range g.nodes {
+		results[name] = n.result
+	}
+	return results, g.err
+}
+
+// DOT returns graph in Graphviz DOT format. Nodes of submitted graph are labeled with their state
+func (g *Graph) DOT() string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	for _, name := range g.order {
+		n := g.nodes[name]
+		label := name
+		if n.done {
+			label += "\n" + n.result.State.String()
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q];\n", name, label)
+	}
+	for _, name := range g.order {
+		for _, dep := range g.nodes[name].deps {
+			fmt.Fprintf(&b, "\t%q -> %q;\n", dep, name)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/antonmashko/taskq"
+)
+
+// orderTask records names of executed nodes
+type orderTask struct {
+	lock  sync.Mutex
+	order []string
+}
+
+func (o *orderTask) node(name string, err error) taskq.Task {
+	return taskq.ValueFunc(func(ctx context.Context) (interface{}, error) {
+		o.lock.Lock()
+		o.order = append(o.order, name)
+		o.lock.Unlock()
+		return name + "-value", err
+	})
+}
+
+func (o *orderTask) index(name string) int {
+	for i, n := range o.order {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestGraph_Diamond(t *testing.T) {
+	tq := taskq.New(4)
+	tq.Start()
+	defer tq.Close()
+	o := &orderTask{}
+	g := taskq.NewGraph(tq)
+	g.Add("D", o.node("D", nil), "B", "C")
+	g.Add("B", o.node("B", nil), "A")
+	g.Add("C", o.node("C", nil), "A")
+	g.Add("A", o.node("A", nil))
+	if err := g.Submit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	results, err := g.Wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.index("A") != 0 || o.index("D") != 3 {
+		t.Fatalf("invalid order: %v", o.order)
+	}
+	for _, name := range []string{"A", "B", "C", "D"} {
+		r := results[name]
+		if r.State != taskq.StateSucceeded || r.Value != name+"-value" {
+			t.Fatalf("invalid result of %s: %+v", name, r)
+		}
+	}
+}
+
+func TestGraph_SkipDependents(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	o := &orderTask{}
+	testErr := errors.New("test")
+	g := taskq.NewGraph(tq)
+	g.Add("A", o.node("A", nil))
+	g.Add("B", o.node("B", testErr), "A")
+	g.Add("C", o.node("C", nil), "A")
+	g.Add("D", o.node("D", nil), "B", "C")
+	g.Submit(context.Background())
+	results, err := g.Wait(context.Background())
+	if !errors.Is(err, testErr) {
+		t.Fatalf("invalid error. expected=%s got=%v", testErr, err)
+	}
+	if results["B"].State != taskq.StateFailed || results["C"].State != taskq.StateSucceeded {
+		t.Fatalf("invalid results: %+v", results)
+	}
+	if r := results["D"]; r.State != taskq.StateCancelled || r.Err != taskq.ErrDependencyFailed || o.index("D") != -1 {
+		t.Fatalf("dependent wasn't skipped: %+v", r)
+	}
+}
+
+func TestGraph_CancelAll(t *testing.T) {
+	tq := taskq.New(2)
+	tq.Start()
+	defer tq.Close()
+	testErr := errors.New("test")
+	g := taskq.NewGraph(tq)
+	g.OnFailure = taskq.GraphCancelAll
+	started := make(chan struct{})
+	g.Add("slow", taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	g.Add("fail", taskq.TaskFunc(func(ctx context.Context) error {
+		<-started
+		return testErr
+	}))
+	g.Add("next", noopTask(), "fail")
+	g.Submit(context.Background())
+	results, err := g.Wait(context.Background())
+	if !errors.Is(err, testErr) {
+		t.Fatalf("invalid error. expected=%s got=%v", testErr, err)
+	}
+	if r := results["slow"]; r.State != taskq.StateCancelled || r.Err != taskq.ErrTaskCancelled {
+		t.Fatalf("running node wasn't cancelled: %+v", r)
+	}
+	if r := results["next"]; r.Err != taskq.ErrDependencyFailed {
+		t.Fatalf("not started node wasn't skipped: %+v", r)
+	}
+}
+
+func TestGraph_Validation(t *testing.T) {
+	g := taskq.NewGraph(taskq.New(1))
+	g.Add("A", noopTask(), "C")
+	g.Add("B", noopTask(), "A")
+	g.Add("C", noopTask(), "B")
+	g.Add("D", noopTask())
+	if err := g.Add("D", noopTask()); !errors.Is(err, taskq.ErrDuplicateNode) {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrDuplicateNode, err)
+	}
+	err := g.Submit(context.Background())
+	if !errors.Is(err, taskq.ErrGraphCycle) || !strings.HasSuffix(err.Error(), "A, B, C") {
+		t.Fatalf("invalid error: %v", err)
+	}
+	g = taskq.NewGraph(taskq.New(1))
+	g.Add("A", noopTask(), "X")
+	if err := g.Submit(context.Background()); !errors.Is(err, taskq.ErrUnknownNode) {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrUnknownNode, err)
+	}
+}
+
+func TestGraph_DOT(t *testing.T) {
+	g := taskq.NewGraph(taskq.New(1))
+	g.Add("A", noopTask())
+	g.Add("B", noopTask(), "A")
+	expected := "digraph {\n\t\"A\" [label=\"A\"];\n\t\"B\" [label=\"B\"];\n\t\"A\" -> \"B\";\n}\n"
+	if dot := g.DOT(); dot != expected {
+		t.Fatalf("invalid DOT:\n%s", dot)
+	}
+}
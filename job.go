@@ -0,0 +1,169 @@
+package taskq
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// job is an Item with execution state kept by TaskQ
+type job struct {
+	Item
+	attempt int
+	err     error     // last error of task
+	at      time.Time // due time of delayed job
+	started time.Time // start time of the last attempt
+	index   int       // index in jobHeap
+	// delivery of AckQueue, nil for other queues
+	delivery Delivery
+	// stopLease stops renewal of delivery lease
+	stopLease func()
+	// admitted is set when rate limit tokens are reserved for the next start
+	admitted bool
+}
+
+// jobList is a FIFO list of jobs ready for execution
+type jobList struct {
+	lock sync.Mutex
+	jobs []*job
+}
+
+func (l *jobList) push(j *job) {
+	l.lock.Lock()
+	l.jobs = append(l.jobs, j)
+	l.lock.Unlock()
+}
+
+func (l *jobList) pop() *job {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.jobs) == 0 {
+		return nil
+	}
+	j := l.jobs[0]
+	l.jobs[0] = nil
+	l.jobs = l.jobs[1:]
+	return j
+}
+
+// remove takes job with given ID from list. Returns nil if job is not found
+func (l *jobList) remove(id int64) *job {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i, j := range l.jobs {
+		if j.ID == id {
+			copy(l.jobs[i:], l.jobs[i+1:])
+			l.jobs[len(l.jobs)-1] = nil
+			l.jobs = l.jobs[:len(l.jobs)-1]
+			return j
+		}
+	}
+	return nil
+}
+
+func (l *jobList) drain() []*job {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	jobs := l.jobs
+	l.jobs = nil
+	return jobs
+}
+
+// jobHeap is a min-heap of jobs ordered by due time
+type jobHeap []*job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*h)
+	*h = append(*h, j)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	j.index = -1
+	*h = old[:n-1]
+	return j
+}
+
+// delayedJobs holds jobs until their due time using a single timer
+type delayedJobs struct {
+	lock  sync.Mutex
+	jobs  jobHeap
+	timer *time.Timer
+	due   func(*job)
+}
+
+func (d *delayedJobs) push(j *job) {
+	d.lock.Lock()
+	heap.Push(&d.jobs, j)
+	if d.jobs[0] == j {
+		d.resetTimer(time.Until(j.at))
+	}
+	d.lock.Unlock()
+}
+
+func (d *delayedJobs) fire() {
+	var ready []*job
+	d.lock.Lock()
+	now := time.Now()
+	for len(d.jobs) > 0 && !d.jobs[0].at.After(now) {
+		ready = append(ready, heap.Pop(&d.jobs).(*job))
+	}
+	if len(d.jobs) > 0 {
+		d.resetTimer(time.Until(d.jobs[0].at))
+	}
+	d.lock.Unlock()
+	for _, j := range ready {
+		d.due(j)
+	}
+}
+
+func (d *delayedJobs) resetTimer(dur time.Duration) {
+	if d.timer == nil {
+		d.timer = time.AfterFunc(dur, d.fire)
+		return
+	}
+	d.timer.Stop()
+	d.timer.Reset(dur)
+}
+
+// remove takes job with given ID. Returns nil if job is not found
+func (d *delayedJobs) remove(id int64) *job {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, j := range d.jobs {
+		if j.ID == id {
+			heap.Remove(&d.jobs, j.index)
+			return j
+		}
+	}
+	return nil
+}
+
+func (d *delayedJobs) len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.jobs)
+}
+
+func (d *delayedJobs) drain() []*job {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	jobs := []*job(d.jobs)
+	d.jobs = nil
+	return jobs
+}
@@ -0,0 +1,109 @@
+package taskq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeaseDelivery is an optional Delivery extension for queues with visibility timeout.
+// Task is invisible for other consumers until its lease expires.
+// TaskQ extends the lease until delivery is acknowledged
+type LeaseDelivery interface {
+	// Lease returns expiration time of the lease. Zero means lease never expires
+	Lease() time.Time
+	// Extend prolongs the lease and returns its new expiration time
+	Extend(ctx context.Context) (time.Time, error)
+}
+
+// ReclaimQueue is an optional Queue extension for queues with leases.
+// TaskQ.Start calls Reclaim for redelivering tasks whose lease expired, e.g. after crash of a consumer
+type ReclaimQueue interface {
+	// Reclaim returns tasks with expired lease into queue and returns their number
+	Reclaim(ctx context.Context) (int, error)
+}
+
+type ctxDeliveriesKey struct{}
+
+// DeliveryCount returns the number of times task was delivered by AckQueue including the current one.
+// Returns 0 if queue doesn't implement AckQueue
+func DeliveryCount(ctx context.Context) int {
+	count, _ := ctx.Value(ctxDeliveriesKey{}).(int)
+	return count
+}
+
+// heartbeat extends lease of delivery at half of its remaining time. Returns idempotent func stopping renewal
+func (t *TaskQ) heartbeat(j *job) func() {
+	ld, ok := j.delivery.(LeaseDelivery)
+	if !ok || ld == nil || ld.Lease().IsZero() {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		lease := ld.Lease()
+		for !lease.IsZero() {
+			timer := time.NewTimer(time.Until(lease) / 2)
+			select {
+			case <-done:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			var err error
+			lease, err = ld.Extend(context.Background())
+			if err != nil {
+				t.ackError(j, err)
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (d *ackDelivery) Lease() time.Time {
+	d.q.lock.Lock()
+	defer d.q.lock.Unlock()
+	if u, ok := d.q.unacked[d.it.ID]; ok && u.token == d.token {
+		return u.lease
+	}
+	return time.Time{}
+}
+
+func (d *ackDelivery) Extend(_ context.Context) (time.Time, error) {
+	d.q.lock.Lock()
+	defer d.q.lock.Unlock()
+	u, ok := d.q.unacked[d.it.ID]
+	if !ok || u.token != d.token {
+		return time.Time{}, ErrUnknownDelivery
+	}
+	if d.q.VisibilityTimeout > 0 {
+		u.lease = time.Now().Add(d.q.VisibilityTimeout)
+	}
+	return u.lease, nil
+}
+
+// Reclaim returns deliveries with expired lease into queue
+func (q *ConcurrentQueue) Reclaim(_ context.Context) (int, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.reclaim(), nil
+}
+
+// reclaim moves deliveries with expired lease to the end of queue. Must be called under lock
+func (q *ConcurrentQueue) reclaim() int {
+	if q.VisibilityTimeout <= 0 {
+		return 0
+	}
+	now := time.Now()
+	var n int
+	for id, u := range q.unacked {
+		if u.lease.IsZero() || u.lease.After(now) {
+			continue
+		}
+		delete(q.unacked, id)
+		q.queue = append(q.queue, u.Item)
+		n++
+	}
+	return n
+}
@@ -0,0 +1,104 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestConcurrentQueueLease_Redelivery(t *testing.T) {
+	ctx := context.Background()
+	q := taskq.NewConcurrentQueue()
+	q.VisibilityTimeout = 20 * time.Millisecond
+	q.Enqueue(ctx, noopTask())
+	d, _ := q.DequeueDelivery(ctx)
+	if _, err := q.DequeueDelivery(ctx); err != taskq.EmptyQueue {
+		t.Fatalf("task redelivered before lease expired. err=%v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	d2, err := q.DequeueDelivery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2.Item().Deliveries != 2 {
+		t.Fatalf("invalid deliveries. expected=2 got=%d", d2.Item().Deliveries)
+	}
+	// expired delivery can't acknowledge redelivered task
+	if err := d.Ack(ctx); err != taskq.ErrUnknownDelivery {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrUnknownDelivery, err)
+	}
+	if err := d2.Ack(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLease_ExtendedWhileRunning(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	q.VisibilityTimeout = 20 * time.Millisecond
+	tq := taskq.NewWithQueue(1, q)
+	tq.Start()
+	var runs, deliveries int32
+	started := make(chan struct{})
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		atomic.StoreInt32(&deliveries, int32(taskq.DeliveryCount(ctx)))
+		close(started)
+		time.Sleep(80 * time.Millisecond)
+		return nil
+	}))
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	if _, err := q.DequeueDelivery(context.Background()); err != taskq.EmptyQueue {
+		t.Fatalf("running task redelivered. err=%v", err)
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 || deliveries != 1 || q.Unacked() != 0 {
+		t.Fatalf("invalid state. runs=%d deliveries=%d unacked=%d", runs, deliveries, q.Unacked())
+	}
+}
+
+func TestLease_ReclaimOnStart(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	q.VisibilityTimeout = 10 * time.Millisecond
+	var deliveries int32
+	q.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.StoreInt32(&deliveries, int32(taskq.DeliveryCount(ctx)))
+		return nil
+	}))
+	// consumer crashed after dequeue
+	q.DequeueDelivery(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	tq := taskq.NewWithQueue(1, q)
+	tq.Start()
+	defer tq.Close()
+	waitFor(t, func() bool { return atomic.LoadInt32(&deliveries) == 2 })
+}
+
+func TestLease_RequeuedOnShutdownDeadline(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	q.VisibilityTimeout = time.Second
+	tq := taskq.NewWithQueue(1, q)
+	tq.ShutdownGracePeriod = time.Second
+	tq.Start()
+	started := make(chan struct{})
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tq.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected=%s got=%v", context.DeadlineExceeded, err)
+	}
+	if q.Len(context.Background()) != 1 || q.Unacked() != 0 {
+		t.Fatalf("task wasn't requeued. len=%d unacked=%d", q.Len(context.Background()), q.Unacked())
+	}
+}
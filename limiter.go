@@ -0,0 +1,141 @@
+package taskq
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimiter adjusts the number of concurrently running tasks
+// by observing their execution. Effective limit is bounded by TaskQ worker limit
+type ConcurrencyLimiter interface {
+	Limit() int
+	// Observe is called after every Task.Do with its latency and error
+	Observe(latency time.Duration, err error)
+}
+
+// defaultBackoffRatio is used when AIMDLimiter.BackoffRatio is not in (0, 1)
+const defaultBackoffRatio = 0.9
+
+// AIMDLimiter increases limit by one after `limit` successful tasks
+// and multiplies it by BackoffRatio on error or latency above LatencyThreshold.
+// Limit is never below 1, so zero value starts with a single task
+type AIMDLimiter struct {
+	lock  sync.Mutex
+	limit float64
+
+	// MinLimit below 1 means 1. MaxLimit below MinLimit means MinLimit
+	MinLimit, MaxLimit int
+	// LatencyThreshold is a task latency treated as overload. Zero means only errors are overload
+	LatencyThreshold time.Duration
+	// BackoffRatio decreases limit on overload. Valid values: (0, 1), otherwise 0.9 is used
+	BackoffRatio float64
+}
+
+func NewAIMDLimiter(initial, min, max int, latencyThreshold time.Duration) *AIMDLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if initial < min {
+		initial = min
+	}
+	return &AIMDLimiter{
+		limit:            float64(initial),
+		MinLimit:         min,
+		MaxLimit:         max,
+		LatencyThreshold: latencyThreshold,
+		BackoffRatio:     defaultBackoffRatio,
+	}
+}
+
+func (l *AIMDLimiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.clamp()
+	return int(l.limit)
+}
+
+func (l *AIMDLimiter) Observe(latency time.Duration, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.clamp()
+	if err != nil || (l.LatencyThreshold > 0 && latency > l.LatencyThreshold) {
+		ratio := l.BackoffRatio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = defaultBackoffRatio
+		}
+		l.limit *= ratio
+	} else {
+		l.limit += 1 / l.limit
+	}
+	l.clamp()
+}
+
+// clamp keeps limit within [MinLimit, MaxLimit] and not below 1.
+// Struct literal and changed fields are handled the same way
+func (l *AIMDLimiter) clamp() {
+	min, max := float64(l.MinLimit), float64(l.MaxLimit)
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if l.limit < min {
+		l.limit = min
+	}
+	if l.limit > max {
+		l.limit = max
+	}
+}
+
+// concurrencyLimit returns effective limit of busy workers
+func (t *TaskQ) concurrencyLimit() int {
+	limit := t.pool.size()
+	if t.ConcurrencyLimiter != nil {
+		if l := t.ConcurrencyLimiter.Limit(); l < limit {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// acquireSlot reserves a place for running task within ConcurrencyLimiter limit
+func (t *TaskQ) acquireSlot() bool {
+	if t.ConcurrencyLimiter == nil {
+		return true
+	}
+	for {
+		active := atomic.LoadInt32(&t.active)
+		if int(active) >= t.ConcurrencyLimiter.Limit() {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&t.active, active, active+1) {
+			return true
+		}
+	}
+}
+
+func (t *TaskQ) releaseSlot() {
+	if t.ConcurrencyLimiter != nil {
+		atomic.AddInt32(&t.active, -1)
+	}
+}
+
+// overLimit returns true if all ConcurrencyLimiter slots are taken
+func (t *TaskQ) overLimit() bool {
+	return t.ConcurrencyLimiter != nil &&
+		int(atomic.LoadInt32(&t.active)) >= t.ConcurrencyLimiter.Limit()
+}
+
+// observe passes task execution sample to ConcurrencyLimiter and starts a new worker if limit increased
+func (t *TaskQ) observe(latency time.Duration, err error) {
+	before := t.ConcurrencyLimiter.Limit()
+	t.ConcurrencyLimiter.Observe(latency, err)
+	if t.ConcurrencyLimiter.Limit() > before {
+		t.triggerDequeue()
+	}
+}
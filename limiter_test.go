@@ -0,0 +1,117 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestAIMDLimiter_Ok(t *testing.T) {
+	l := taskq.NewAIMDLimiter(2, 1, 4, 10*time.Millisecond)
+	// every successful sample adds 1/limit
+	for i := 0; i < 3; i++ {
+		l.Observe(time.Millisecond, nil)
+	}
+	if l.Limit() != 3 {
+		t.Fatalf("invalid limit. expected=3 got=%d", l.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		l.Observe(time.Millisecond, nil)
+	}
+	if l.Limit() != 4 {
+		t.Fatalf("limit exceeded max. expected=4 got=%d", l.Limit())
+	}
+	l.Observe(time.Millisecond, errors.New("test"))
+	if l.Limit() != 3 {
+		t.Fatalf("limit wasn't decreased on error. expected=3 got=%d", l.Limit())
+	}
+	l.Observe(20*time.Millisecond, nil)
+	if l.Limit() != 3 {
+		t.Fatalf("limit wasn't decreased on latency. expected=3 got=%d", l.Limit())
+	}
+	for i := 0; i < 20; i++ {
+		l.Observe(time.Millisecond, errors.New("test"))
+	}
+	if l.Limit() != 1 {
+		t.Fatalf("limit exceeded min. expected=1 got=%d", l.Limit())
+	}
+}
+
+func TestAIMDLimiterZeroValue_Ok(t *testing.T) {
+	l := &taskq.AIMDLimiter{MaxLimit: 4}
+	if l.Limit() != 1 {
+		t.Fatalf("invalid limit. expected=1 got=%d", l.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		l.Observe(time.Millisecond, errors.New("test"))
+	}
+	if l.Limit() != 1 {
+		t.Fatalf("limit is below 1. got=%d", l.Limit())
+	}
+	for i := 0; i < 10; i++ {
+		l.Observe(time.Millisecond, nil)
+	}
+	if l.Limit() != 4 {
+		t.Fatalf("invalid limit. expected=4 got=%d", l.Limit())
+	}
+
+	tq := taskq.New(2)
+	tq.ConcurrencyLimiter = &taskq.AIMDLimiter{}
+	tq.Start()
+	var n int32
+	for i := 0; i < 3; i++ {
+		tq.Enqueue(context.Background(), countTask(&n))
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&n); n != 3 {
+		t.Fatalf("invalid executed tasks. expected=3 got=%d", n)
+	}
+}
+
+func TestConcurrencyLimiter_LimitsRunningTasks(t *testing.T) {
+	tq := taskq.New(4)
+	tq.ConcurrencyLimiter = taskq.NewAIMDLimiter(2, 2, 2, 0)
+	tq.Start()
+	c := &concurrencyTask{release: make(chan struct{})}
+	for i := 0; i < 6; i++ {
+		tq.Enqueue(context.Background(), c.task())
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&c.active) == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if m := atomic.LoadInt32(&c.max); m != 2 {
+		t.Fatalf("invalid max concurrency. expected=2 got=%d", m)
+	}
+	if s := tq.Stats(); s.ConcurrencyLimit != 2 {
+		t.Fatalf("invalid concurrency limit. expected=2 got=%d", s.ConcurrencyLimit)
+	}
+	close(c.release)
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if s := tq.Stats(); s.Succeeded != 6 {
+		t.Fatalf("invalid succeeded. expected=6 got=%d", s.Succeeded)
+	}
+}
+
+func TestConcurrencyLimiter_DecreaseOnErrors(t *testing.T) {
+	tq := taskq.New(4)
+	tq.ConcurrencyLimiter = taskq.NewAIMDLimiter(4, 1, 4, 0)
+	tq.Start()
+	for i := 0; i < 20; i++ {
+		tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+			return errors.New("test")
+		}))
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if s := tq.Stats(); s.ConcurrencyLimit != 1 || s.Failed != 20 {
+		t.Fatalf("invalid stats. limit=%d failed=%d", s.ConcurrencyLimit, s.Failed)
+	}
+}
@@ -0,0 +1,148 @@
+package taskq
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// errPartitionBusy is returned by next when dequeued job waits for its partition
+var errPartitionBusy = errors.New("partition busy")
+
+// TaskPartition is an optional Task extension for tasks which must be executed in order, e.g. tasks of the same account.
+// Tasks with the same non-empty key are executed one at a time in order of dequeuing, including their retries.
+// Task of busy partition doesn't hold a worker, it waits until the previous task of partition is finished
+type TaskPartition interface {
+	PartitionKey() string
+}
+
+type partition struct {
+	// active is a job holding partition
+	active *job
+	// waiting jobs in FIFO order
+	waiting []*job
+}
+
+// partitions keeps partitions with active jobs
+type partitions struct {
+	lock sync.Mutex
+	keys map[string]*partition
+}
+
+// enter makes job active in partition. Returns false if partition is busy,
+// in this case job waits in partition if `wait` is set
+func (p *partitions) enter(key string, j *job, wait bool) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	part, ok := p.keys[key]
+	if !ok {
+		if p.keys == nil {
+			p.keys = make(map[string]*partition)
+		}
+		p.keys[key] = &partition{active: j}
+		return true
+	}
+	if part.active == j {
+		return true
+	}
+	if wait {
+		part.waiting = append(part.waiting, j)
+	}
+	return false
+}
+
+// leave releases partition held by job and returns the next job of partition
+func (p *partitions) leave(key string, j *job) *job {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	part, ok := p.keys[key]
+	if !ok || part.active != j {
+		return nil
+	}
+	if len(part.waiting) == 0 {
+		delete(p.keys, key)
+		return nil
+	}
+	next := part.waiting[0]
+	part.waiting[0] = nil
+	part.waiting = part.waiting[1:]
+	part.active = next
+	return next
+}
+
+// remove takes waiting job with given ID. Returns nil if job is not found
+func (p *partitions) remove(id int64) *job {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, part := range p.keys {
+		for i, j := range part.waiting {
+			if j.ID == id {
+				copy(part.waiting[i:], part.waiting[i+1:])
+				part.waiting[len(part.waiting)-1] = nil
+				part.waiting = part.waiting[:len(part.waiting)-1]
+				return j
+			}
+		}
+	}
+	return nil
+}
+
+// len returns the number of waiting jobs
+func (p *partitions) len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	n := 0
+	for _, part := range p.keys {
+		n += len(part.waiting)
+	}
+	return n
+}
+
+// drain takes all waiting jobs
+func (p *partitions) drain() []*job {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var jobs []*job
+	for _, part := range p.keys {
+		jobs = append(jobs, part.waiting...)
+		part.waiting = nil
+	}
+	return jobs
+}
+
+func partitionKey(task Task) string {
+	var key string
+	findTask(task, func(task Task) bool {
+		tp, ok := task.(TaskPartition)
+		if ok && tp != nil {
+			key = tp.PartitionKey()
+		}
+		return ok
+	})
+	return key
+}
+
+// enterPartition returns errPartitionBusy if job must wait for its partition
+func (t *TaskQ) enterPartition(j *job) error {
+	key := partitionKey(j.Task)
+	if key == "" {
+		return nil
+	}
+	if !t.partitions.enter(key, j, true) {
+		atomic.AddInt32(&t.pending, 1)
+		return errPartitionBusy
+	}
+	return nil
+}
+
+// leavePartition passes partition of finished job to the next job of partition
+func (t *TaskQ) leavePartition(j *job) {
+	key := partitionKey(j.Task)
+	if key == "" {
+		return
+	}
+	if next := t.partitions.leave(key, j); next != nil {
+		t.ready.push(next)
+		t.notify()
+	}
+}
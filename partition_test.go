@@ -0,0 +1,195 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+type partitionTask struct {
+	key string
+	do  func(ctx context.Context) error
+}
+
+func (t partitionTask) Do(ctx context.Context) error {
+	return t.do(ctx)
+}
+
+func (t partitionTask) PartitionKey() string {
+	return t.key
+}
+
+func TestPartition_OrderedPerKey(t *testing.T) {
+	tq := taskq.New(4)
+	tq.Start()
+	var lock sync.Mutex
+	order := make(map[string][]int)
+	active := make(map[string]int)
+	var overlap int32
+	for i := 0; i < 30; i++ {
+		key, n := fmt.Sprint("key-", i%3), i
+		tq.Enqueue(context.Background(), partitionTask{key: key, do: func(ctx context.Context) error {
+			lock.Lock()
+			if active[key]++; active[key] > 1 {
+				atomic.StoreInt32(&overlap, 1)
+			}
+			order[key] = append(order[key], n)
+			lock.Unlock()
+			time.Sleep(time.Millisecond)
+			lock.Lock()
+			active[key]--
+			lock.Unlock()
+			return nil
+		}})
+	}
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&overlap) == 1 {
+		t.Fatal("tasks of the same partition ran concurrently")
+	}
+	for key, ns := range order {
+		if len(ns) != 10 {
+			t.Fatalf("expected 10 tasks of %s, got %d", key, len(ns))
+		}
+		for i := 1; i < len(ns); i++ {
+			if ns[i] < ns[i-1] {
+				t.Fatalf("tasks of %s ran out of order: %v", key, ns)
+			}
+		}
+	}
+}
+
+func TestPartition_HotKeyDoesNotHoldWorkers(t *testing.T) {
+	tq := taskq.New(2)
+	tq.Start()
+	defer tq.Close()
+	release := make(chan struct{})
+	var hot, cold int32
+	for i := 0; i < 4; i++ {
+		tq.Enqueue(context.Background(), partitionTask{key: "hot", do: func(ctx context.Context) error {
+			<-release
+			atomic.AddInt32(&hot, 1)
+			return nil
+		}})
+	}
+	tq.Enqueue(context.Background(), partitionTask{key: "cold", do: func(ctx context.Context) error {
+		atomic.AddInt32(&cold, 1)
+		return nil
+	}})
+	waitFor(t, func() bool { return atomic.LoadInt32(&cold) == 1 })
+	waitFor(t, func() bool { return tq.Stats().PartitionWaiting == 3 })
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&hot) == 4 })
+}
+
+func TestPartition_RetryKeepsOrder(t *testing.T) {
+	tq := taskq.New(2)
+	tq.RetryPolicy = &taskq.RetryPolicy{MaxAttempts: 2, InitialInterval: 20 * time.Millisecond}
+	tq.Start()
+	var lock sync.Mutex
+	var order []string
+	attempts := 0
+	tq.Enqueue(context.Background(), partitionTask{key: "a", do: func(ctx context.Context) error {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		order = append(order, fmt.Sprint("first-", attempts))
+		if attempts == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}})
+	tq.Enqueue(context.Background(), partitionTask{key: "a", do: func(ctx context.Context) error {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, "second")
+		return nil
+	}})
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(order) != "[first-1 first-2 second]" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+}
+
+func TestPartition_CancelWaiting(t *testing.T) {
+	tq := taskq.New(2)
+	tq.Start()
+	defer tq.Close()
+	release := make(chan struct{})
+	var done int32
+	tq.Enqueue(context.Background(), partitionTask{key: "a", do: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+	id, _ := tq.Enqueue(context.Background(), partitionTask{key: "a", do: func(ctx context.Context) error {
+		atomic.AddInt32(&done, 1)
+		return nil
+	}})
+	tq.Enqueue(context.Background(), partitionTask{key: "a", do: func(ctx context.Context) error {
+		atomic.AddInt32(&done, 2)
+		return nil
+	}})
+	waitFor(t, func() bool { return tq.Stats().PartitionWaiting == 2 })
+	if err := tq.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&done); n != 2 {
+		t.Fatalf("cancelled task was executed: %d", n)
+	}
+	if s, _ := tq.Status(context.Background(), id); s.State != taskq.StateCancelled {
+		t.Fatalf("expected cancelled status, got %s", s.State)
+	}
+}
+
+func TestPartition_CallerRunsRejectsBusy(t *testing.T) {
+	tq := taskq.New(4)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowCallerRuns
+	tq.Start()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var order []int
+	var lock sync.Mutex
+	task := func(n int) taskq.Task {
+		return partitionTask{key: "a", do: func(ctx context.Context) error {
+			if n == 1 {
+				close(started)
+				<-release
+			}
+			lock.Lock()
+			order = append(order, n)
+			lock.Unlock()
+			return nil
+		}}
+	}
+	tq.Enqueue(context.Background(), task(1))
+	<-started
+	// second task fills the queue while workers are paused
+	tq.Pause()
+	if _, err := tq.Enqueue(context.Background(), task(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tq.Enqueue(context.Background(), task(3)); err != taskq.ErrQueueFull {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrQueueFull, err)
+	}
+	tq.Resume()
+	close(release)
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(order) != "[1 2]" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+}
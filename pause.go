@@ -0,0 +1,88 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrNotStarted is returned by Stop when TaskQ is not running
+var ErrNotStarted = errors.New("taskq not started")
+
+// Pause stops workers from taking new tasks. Active tasks keep running and Enqueue keeps accepting tasks
+func (t *TaskQ) Pause() {
+	atomic.StoreInt32(&t.isPaused, 1)
+	t.updateHalt()
+}
+
+// Resume continues execution of tasks after Pause
+func (t *TaskQ) Resume() {
+	if !atomic.CompareAndSwapInt32(&t.isPaused, 1, 0) {
+		return
+	}
+	t.updateHalt()
+	t.restart()
+}
+
+// Paused returns true if TaskQ is paused
+func (t *TaskQ) Paused() bool {
+	return atomic.LoadInt32(&t.isPaused) == 1
+}
+
+// Stop stops workers and polling and waits for active tasks to finish. Unlike Shutdown,
+// tasks are left in queue and TaskQ can be started again with Start.
+// Returns ctx error if ctx is done first, in this case active tasks keep running
+func (t *TaskQ) Stop(ctx context.Context) error {
+	if atomic.LoadInt32(&t.isClosed) != 0 {
+		return ErrClosed
+	}
+	if !atomic.CompareAndSwapInt32(&t.isRunning, 1, 0) {
+		return ErrNotStarted
+	}
+	t.updateHalt()
+	return t.waitWorkers(ctx, false)
+}
+
+// halted returns true if workers must not take new tasks
+func (t *TaskQ) halted() bool {
+	return atomic.LoadInt32(&t.isRunning) != 1 || atomic.LoadInt32(&t.isPaused) == 1
+}
+
+// updateHalt closes halt channel for releasing idle workers and polling when TaskQ is paused or stopped
+// and replaces it with a new one when TaskQ is running again
+func (t *TaskQ) updateHalt() {
+	t.haltLock.Lock()
+	select {
+	case <-t.halt:
+		if !t.halted() {
+			t.halt = make(chan struct{})
+		}
+	default:
+		if t.halted() {
+			close(t.halt)
+		}
+	}
+	t.haltLock.Unlock()
+	if t.halted() {
+		t.stopListen()
+	}
+}
+
+func (t *TaskQ) haltSignal() <-chan struct{} {
+	t.haltLock.Lock()
+	defer t.haltLock.Unlock()
+	return t.halt
+}
+
+// restart starts polling and workers for tasks enqueued while TaskQ was halted
+func (t *TaskQ) restart() {
+	if t.halted() {
+		return
+	}
+	if t.Poll != nil && t.Poll.Interval > 0 {
+		go t.poll(t.Poll, t.haltSignal())
+	}
+	// worker exiting on halt starts a new one
+	atomic.StoreInt32(&t.signal, 1)
+	t.triggerFreeWorkers()
+}
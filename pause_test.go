@@ -0,0 +1,113 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func countTask(done *int32) taskq.Task {
+	return taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(done, 1)
+		return nil
+	})
+}
+
+func TestPauseResume_Ok(t *testing.T) {
+	tq := taskq.New(2)
+	tq.KeepIdleWorkers(2, 0)
+	tq.Start()
+	defer tq.Close()
+	var done int32
+	tq.Pause()
+	if !tq.Paused() {
+		t.Fatal("expected paused TaskQ")
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := tq.Enqueue(context.Background(), countTask(&done)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&done); n != 0 {
+		t.Fatalf("expected no tasks executed while paused, got %d", n)
+	}
+	tq.Resume()
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 5 })
+}
+
+func TestPause_ActiveTaskFinishes(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var done int32
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-release
+		atomic.AddInt32(&done, 1)
+		return nil
+	}))
+	<-started
+	tq.Pause()
+	tq.Enqueue(context.Background(), countTask(&done))
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&done); n != 1 {
+		t.Fatalf("expected only active task finished, got %d", n)
+	}
+	tq.Resume()
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 2 })
+}
+
+func TestStopStart_Ok(t *testing.T) {
+	tq := taskq.New(2)
+	tq.Start()
+	defer tq.Close()
+	var done int32
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		time.Sleep(30 * time.Millisecond)
+		atomic.AddInt32(&done, 1)
+		return nil
+	}))
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := tq.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&done); n != 1 {
+		t.Fatalf("expected active task finished by Stop, got %d", n)
+	}
+	if err := tq.Stop(ctx); err != taskq.ErrNotStarted {
+		t.Fatalf("expected ErrNotStarted, got %v", err)
+	}
+	tq.Enqueue(context.Background(), countTask(&done))
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&done); n != 1 {
+		t.Fatalf("expected no tasks executed while stopped, got %d", n)
+	}
+	if err := tq.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 2 })
+}
+
+func TestShutdownWithWait_DrainsPaused(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	tq.Pause()
+	var done int32
+	tq.Enqueue(context.Background(), countTask(&done))
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&done); n != 1 {
+		t.Fatalf("expected drained queue, got %d", n)
+	}
+}
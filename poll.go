@@ -0,0 +1,53 @@
+package taskq
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PollPolicy configures polling of queue which is fed by other processes.
+// Poll interval doubles after every empty poll up to MaxInterval and resets when a task is dequeued
+type PollPolicy struct {
+	// Interval between polls of non-empty queue. Zero disables polling
+	Interval time.Duration
+	// MaxInterval limits the delay between polls of empty queue. Zero means Interval
+	MaxInterval time.Duration
+	// Jitter randomizes delay in range [delay*(1-Jitter), delay*(1+Jitter)]. Valid values: [0, 1]
+	Jitter float64
+}
+
+func (p *PollPolicy) backoff() *RetryPolicy {
+	max := p.MaxInterval
+	if max < p.Interval {
+		max = p.Interval
+	}
+	return &RetryPolicy{
+		InitialInterval: p.Interval,
+		MaxInterval:     max,
+		Multiplier:      2,
+		Jitter:          p.Jitter,
+	}
+}
+
+// poll wakes workers for dequeuing until TaskQ is closed or halted
+func (t *TaskQ) poll(p *PollPolicy, halt <-chan struct{}) {
+	backoff := p.backoff()
+	empty := 0
+	for {
+		if atomic.SwapInt32(&t.polled, 0) == 1 {
+			empty = 0
+		}
+		empty++
+		timer := time.NewTimer(backoff.Delay(empty))
+		select {
+		case <-timer.C:
+		case <-t.closing:
+			timer.Stop()
+			return
+		case <-halt:
+			timer.Stop()
+			return
+		}
+		t.notify()
+	}
+}
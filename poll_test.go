@@ -0,0 +1,29 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestPoll_ExternallyFedQueue(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	tq := taskq.NewWithQueue(2, q)
+	tq.Poll = &taskq.PollPolicy{Interval: time.Millisecond, MaxInterval: 10 * time.Millisecond, Jitter: 0.1}
+	tq.Start()
+	defer tq.Close()
+	var done int32
+	task := taskq.TaskFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&done, 1)
+		return nil
+	})
+	time.Sleep(30 * time.Millisecond) // queue is polled while empty
+	// tasks are added bypassing TaskQ.Enqueue
+	for i := 0; i < 3; i++ {
+		q.Enqueue(context.Background(), task)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&done) == 3 })
+}
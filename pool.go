@@ -0,0 +1,86 @@
+package taskq
+
+import (
+	"runtime"
+	"sync"
+)
+
+type worker struct {
+	id uint64
+}
+
+// pool of free workers. Pool size can be changed while TaskQ is running
+type pool struct {
+	lock   sync.Mutex
+	limit  int
+	lastID uint64
+	free   []worker
+	// number of busy workers which must retire after finishing their current task
+	retire int
+}
+
+func (p *pool) acquire() (worker, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.free) == 0 {
+		return worker{}, false
+	}
+	w := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	return w, true
+}
+
+// release returns worker to pool or retires it if pool was shrunk
+func (p *pool) release(w worker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.retire > 0 {
+		p.retire--
+		return
+	}
+	p.free = append(p.free, w)
+}
+
+// retireBusy returns true if busy worker must retire instead of taking the next task
+func (p *pool) retireBusy() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.retire > 0 {
+		p.retire--
+		return true
+	}
+	return false
+}
+
+func (p *pool) freeCount() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.free)
+}
+
+func (p *pool) size() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.limit
+}
+
+func (p *pool) resize(limit int) {
+	if limit <= 0 {
+		limit = runtime.NumCPU()
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	diff := limit - p.limit
+	p.limit = limit
+	for ; diff > 0 && p.retire > 0; diff-- {
+		p.retire--
+	}
+	for ; diff > 0; diff-- {
+		p.lastID++
+		p.free = append(p.free, worker{id: p.lastID})
+	}
+	for ; diff < 0 && len(p.free) > 0; diff++ {
+		p.free = p.free[:len(p.free)-1]
+	}
+	p.retire -= diff
+}
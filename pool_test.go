@@ -0,0 +1,81 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+// concurrencyTask tracks the maximum number of simultaneously running tasks
+type concurrencyTask struct {
+	active, max int32
+	release     chan struct{}
+}
+
+func (c *concurrencyTask) task() taskq.Task {
+	return taskq.TaskFunc(func(ctx context.Context) error {
+		a := atomic.AddInt32(&c.active, 1)
+		for m := atomic.LoadInt32(&c.max); a > m && !atomic.CompareAndSwapInt32(&c.max, m, a); m = atomic.LoadInt32(&c.max) {
+		}
+		<-c.release
+		atomic.AddInt32(&c.active, -1)
+		return nil
+	})
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition wasn't met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestResizeGrow_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	c := &concurrencyTask{release: make(chan struct{})}
+	for i := 0; i < 3; i++ {
+		tq.Enqueue(context.Background(), c.task())
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&c.active) == 1 })
+	tq.Resize(3)
+	waitFor(t, func() bool { return atomic.LoadInt32(&c.active) == 3 })
+	close(c.release)
+	if tq.Limit() != 3 {
+		t.Fatalf("invalid limit. expected=3 got=%d", tq.Limit())
+	}
+}
+
+func TestResizeShrink_Ok(t *testing.T) {
+	tq := taskq.New(3)
+	tq.Start()
+	c := &concurrencyTask{release: make(chan struct{})}
+	for i := 0; i < 3; i++ {
+		tq.Enqueue(context.Background(), c.task())
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&c.active) == 3 })
+	tq.Resize(1)
+	next := &concurrencyTask{release: make(chan struct{})}
+	close(next.release)
+	for i := 0; i < 5; i++ {
+		tq.Enqueue(context.Background(), next.task())
+	}
+	// in-flight tasks are not interrupted
+	if a := atomic.LoadInt32(&c.active); a != 3 {
+		t.Fatalf("active tasks interrupted. active=%d", a)
+	}
+	close(c.release)
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal("shutdown:", err)
+	}
+	if m := atomic.LoadInt32(&next.max); m > 1 {
+		t.Fatalf("pool wasn't shrunk. max active=%d", m)
+	}
+}
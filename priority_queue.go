@@ -0,0 +1,209 @@
+package taskq
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// TaskPriority sets priority of a task in PriorityQueue. Higher value is dequeued first
+type TaskPriority interface {
+	Priority() int
+}
+
+type ctxPriorityKey struct{}
+
+// ContextWithPriority sets priority of enqueued task. It overrides TaskPriority of the task
+func ContextWithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, ctxPriorityKey{}, priority)
+}
+
+func taskPriority(ctx context.Context, task Task) int {
+	if p, ok := ctx.Value(ctxPriorityKey{}).(int); ok {
+		return p
+	}
+	var priority int
+	findTask(task, func(task Task) bool {
+		tp, ok := task.(TaskPriority)
+		if ok && tp != nil {
+			priority = tp.Priority()
+		}
+		return ok
+	})
+	return priority
+}
+
+type priorityItem struct {
+	Item
+	priority int
+	seq      int64
+	index    int
+}
+
+type priorityHeap struct {
+	items []*priorityItem
+	aging time.Duration
+}
+
+// score is an enqueue time shifted back by `priority` aging intervals.
+// Waiting task gains one priority point per aging interval
+func (h *priorityHeap) score(it *priorityItem) time.Time {
+	return it.EnqueuedAt.Add(-time.Duration(it.priority) * h.aging)
+}
+
+func (h *priorityHeap) Len() int { return len(h.items) }
+func (h *priorityHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.aging > 0 {
+		if sa, sb := h.score(a), h.score(b); !sa.Equal(sb) {
+			return sa.Before(sb)
+		}
+	} else if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (h *priorityHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *priorityHeap) Push(x interface{}) {
+	it := x.(*priorityItem)
+	it.index = len(h.items)
+	h.items = append(h.items, it)
+}
+
+func (h *priorityHeap) Pop() interface{} {
+	n := len(h.items)
+	it := h.items[n-1]
+	h.items[n-1] = nil
+	it.index = -1
+	h.items = h.items[:n-1]
+	return it
+}
+
+// PriorityQueue dequeues tasks with higher priority first, tasks with the same priority in FIFO order.
+// With aging, waiting task gains one priority point per aging interval, so low priority tasks are not starved
+type PriorityQueue struct {
+	lock    sync.Mutex
+	lastInc int64
+	heap    priorityHeap
+	byID    map[int64]*priorityItem
+}
+
+// NewPriorityQueue creates queue with aging interval. Zero aging disables aging
+func NewPriorityQueue(aging time.Duration) *PriorityQueue {
+	return &PriorityQueue{
+		heap: priorityHeap{aging: aging},
+		byID: make(map[int64]*priorityItem),
+	}
+}
+
+func (q *PriorityQueue) Enqueue(ctx context.Context, t Task) (int64, error) {
+	priority := taskPriority(ctx, t)
+	now := time.Now()
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.lastInc++
+	it := &priorityItem{
+		Item:     Item{ID: q.lastInc, Task: t, Ctx: ctx, EnqueuedAt: now},
+		priority: priority,
+		seq:      q.lastInc,
+	}
+	heap.Push(&q.heap, it)
+	q.byID[it.ID] = it
+	return it.ID, nil
+}
+
+func (q *PriorityQueue) Dequeue(ctx context.Context) (Task, error) {
+	it, err := q.DequeueItem(ctx)
+	return it.Task, err
+}
+
+func (q *PriorityQueue) DequeueItem(_ context.Context) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.heap.Len() == 0 {
+		return Item{}, EmptyQueue
+	}
+	it := heap.Pop(&q.heap).(*priorityItem)
+	delete(q.byID, it.ID)
+	return it.Item, nil
+}
+
+func (q *PriorityQueue) Peek(_ context.Context) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.heap.Len() == 0 {
+		return Item{}, EmptyQueue
+	}
+	return q.heap.items[0].Item, nil
+}
+
+// SetPriority changes priority of a queued task. Returns false if task is not in queue
+func (q *PriorityQueue) SetPriority(id int64, priority int) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	it, ok := q.byID[id]
+	if !ok {
+		return false
+	}
+	it.priority = priority
+	heap.Fix(&q.heap, it.index)
+	return true
+}
+
+// DropOldest removes the task which is waiting longest
+func (q *PriorityQueue) DropOldest(_ context.Context) (Item, error) {
+	return q.drop(func(a, b *priorityItem) bool {
+		return b.seq < a.seq
+	})
+}
+
+// DropLowestPriority removes the task which would be dequeued last
+func (q *PriorityQueue) DropLowestPriority(_ context.Context) (Item, error) {
+	return q.drop(func(a, b *priorityItem) bool {
+		return q.heap.Less(a.index, b.index)
+	})
+}
+
+// drop removes the last item in order of `less`
+func (q *PriorityQueue) drop(less func(a, b *priorityItem) bool) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.heap.Len() == 0 {
+		return Item{}, EmptyQueue
+	}
+	last := q.heap.items[0]
+	for _, it := range q.heap.items[1:] {
+		if less(last, it) {
+			last = it
+		}
+	}
+	heap.Remove(&q.heap, last.index)
+	delete(q.byID, last.ID)
+	return last.Item, nil
+}
+
+// Remove takes task with given ID from queue
+func (q *PriorityQueue) Remove(_ context.Context, id int64) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	it, ok := q.byID[id]
+	if !ok {
+		return Item{}, ErrTaskNotFound
+	}
+	heap.Remove(&q.heap, it.index)
+	delete(q.byID, id)
+	return it.Item, nil
+}
+
+func (q *PriorityQueue) Len(_ context.Context) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.heap.Len()
+}
@@ -0,0 +1,79 @@
+package taskq_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+type priorityTask struct {
+	testTask
+	priority int
+}
+
+func (t *priorityTask) Priority() int {
+	return t.priority
+}
+
+func dequeueIDs(t *testing.T, q *taskq.PriorityQueue, n int) []int64 {
+	var ids []int64
+	for i := 0; i < n; i++ {
+		it, err := q.DequeueItem(context.Background())
+		if err != nil {
+			t.Fatal("dequeue:", err)
+		}
+		ids = append(ids, it.ID)
+	}
+	return ids
+}
+
+func TestPriorityQueueOrder(t *testing.T) {
+	q := taskq.NewPriorityQueue(0)
+	low, _ := q.Enqueue(context.Background(), &priorityTask{priority: 1})
+	high, _ := q.Enqueue(context.Background(), &priorityTask{priority: 10})
+	def, _ := q.Enqueue(context.Background(), &testTask{})
+	ctxHigh, _ := q.Enqueue(taskq.ContextWithPriority(context.Background(), 10), &priorityTask{priority: -1})
+	ids := dequeueIDs(t, q, 4)
+	for i, expected := range []int64{high, ctxHigh, low, def} {
+		if ids[i] != expected {
+			t.Fatalf("invalid order. expected=%d got=%d (%v)", expected, ids[i], ids)
+		}
+	}
+	if _, err := q.Dequeue(context.Background()); err != taskq.EmptyQueue {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.EmptyQueue, err)
+	}
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	q := taskq.NewPriorityQueue(time.Millisecond)
+	low, _ := q.Enqueue(context.Background(), &priorityTask{priority: 0})
+	time.Sleep(20 * time.Millisecond)
+	// low priority task waited 20 aging intervals
+	high, _ := q.Enqueue(context.Background(), &priorityTask{priority: 10})
+	ids := dequeueIDs(t, q, 2)
+	if ids[0] != low || ids[1] != high {
+		t.Fatalf("low priority task starved. order=%v", ids)
+	}
+}
+
+func TestPriorityQueueSetPriority(t *testing.T) {
+	q := taskq.NewPriorityQueue(0)
+	first, _ := q.Enqueue(context.Background(), &testTask{})
+	second, _ := q.Enqueue(context.Background(), &testTask{})
+	if !q.SetPriority(second, 5) {
+		t.Fatal("task not found")
+	}
+	if q.SetPriority(100, 5) {
+		t.Fatal("unknown task reprioritized")
+	}
+	ids := dequeueIDs(t, q, 2)
+	if ids[0] != second || ids[1] != first {
+		t.Fatalf("invalid order after reprioritizing. order=%v", ids)
+	}
+}
+
+func TestPriorityQueueImplementation(t *testing.T) {
+	var _ taskq.ItemQueue = taskq.NewPriorityQueue(0)
+}
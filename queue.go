@@ -1,9 +1,11 @@
 package taskq
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"sync"
+	"time"
 )
 
 var (
@@ -17,38 +19,184 @@ type Queue interface {
 	Dequeue(context.Context) (Task, error)
 }
 
+// Item is a Task with metadata stored by Queue
+type Item struct {
+	ID   int64
+	Task Task
+	// Ctx is a context passed to Queue.Enqueue. Nil if queue doesn't keep it
+	Ctx context.Context
+	// EnqueuedAt is a time when task was added to queue
+	EnqueuedAt time.Time
+	// Deliveries is a number of times task was delivered by AckQueue, including the current one
+	Deliveries int
+	// Retries is a number of times task was returned into AckQueue by RetryDelivery.Retry
+	Retries int
+}
+
+// ItemQueue is an optional Queue extension for queues that keep
+// the enqueue context of a Task. TaskQ passes values of this context to Task.Do
+type ItemQueue interface {
+	Queue
+	// DequeueItem the same as Dequeue but returns Task with its metadata
+	DequeueItem(context.Context) (Item, error)
+}
+
+// ScheduledQueue is an optional Queue extension for queues that store due time of tasks.
+// Dequeue must not return a task before its due time
+type ScheduledQueue interface {
+	Queue
+	EnqueueAt(ctx context.Context, t Task, at time.Time) (int64, error)
+}
+
+// PeekQueue is an optional Queue extension for inspecting the next task without removing it
+type PeekQueue interface {
+	// Peek returns the next task for dequeue. Returns EmptyQueue if queue is empty
+	Peek(context.Context) (Item, error)
+}
+
 type ConcurrentQueue struct {
 	lock    sync.Mutex
 	lastInc int64
-	queue   []Task
+	queue   []Item
+	// tasks waiting for their due time
+	scheduled jobHeap
+	// delivered tasks waiting for Ack
+	unacked   map[int64]*unackedItem
+	lastToken int64
+	// VisibilityTimeout is a time after which unacknowledged delivery is redelivered.
+	// Zero means delivery is kept until Ack or Nack
+	VisibilityTimeout time.Duration
 }
 
 func NewConcurrentQueue() *ConcurrentQueue {
 	return &ConcurrentQueue{}
 }
 
-func (q *ConcurrentQueue) Enqueue(_ context.Context, t Task) (int64, error) {
+func (q *ConcurrentQueue) Enqueue(ctx context.Context, t Task) (int64, error) {
+	var result int64
+	q.lock.Lock()
+	q.lastInc++
+	result = q.lastInc
+	q.queue = append(q.queue, Item{ID: result, Task: t, Ctx: ctx, EnqueuedAt: time.Now()})
+	q.lock.Unlock()
+	return result, nil
+}
+
+// EnqueueAt adds task that will be dequeued not earlier than `at`
+func (q *ConcurrentQueue) EnqueueAt(ctx context.Context, t Task, at time.Time) (int64, error) {
+	if !at.After(time.Now()) {
+		return q.Enqueue(ctx, t)
+	}
 	var result int64
 	q.lock.Lock()
-	q.queue = append(q.queue, t)
 	q.lastInc++
 	result = q.lastInc
+	heap.Push(&q.scheduled, &job{Item: Item{ID: result, Task: t, Ctx: ctx, EnqueuedAt: time.Now()}, at: at})
 	q.lock.Unlock()
 	return result, nil
 }
 
-func (q *ConcurrentQueue) Dequeue(_ context.Context) (Task, error) {
+func (q *ConcurrentQueue) Dequeue(ctx context.Context) (Task, error) {
+	it, err := q.DequeueItem(ctx)
+	return it.Task, err
+}
+
+// moveDue moves scheduled tasks which are due to the end of queue. Must be called under lock
+func (q *ConcurrentQueue) moveDue() {
+	if len(q.scheduled) == 0 {
+		return
+	}
+	now := time.Now()
+	for len(q.scheduled) > 0 && !q.scheduled[0].at.After(now) {
+		q.queue = append(q.queue, heap.Pop(&q.scheduled).(*job).Item)
+	}
+}
+
+func (q *ConcurrentQueue) Peek(_ context.Context) (Item, error) {
 	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.moveDue()
 	if len(q.queue) == 0 {
-		q.lock.Unlock()
-		return nil, EmptyQueue
+		return Item{}, EmptyQueue
+	}
+	return q.queue[0], nil
+}
+
+func (q *ConcurrentQueue) DequeueItem(_ context.Context) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	it, ok := q.pop()
+	if !ok {
+		return Item{}, EmptyQueue
+	}
+	return it, nil
+}
+
+// pop removes the first due task from queue. Must be called under lock
+func (q *ConcurrentQueue) pop() (Item, bool) {
+	q.moveDue()
+	if len(q.queue) == 0 {
+		return Item{}, false
 	}
 	it := q.queue[0]
+	q.queue[0] = Item{}
 	q.queue = q.queue[1:]
-	q.lock.Unlock()
+	return it, true
+}
+
+// DropOldest removes the first task in queue. Scheduled tasks are not dropped
+func (q *ConcurrentQueue) DropOldest(ctx context.Context) (Item, error) {
+	return q.DequeueItem(ctx)
+}
+
+// DropLowestPriority removes the latest task with the lowest TaskPriority. Scheduled tasks are not dropped
+func (q *ConcurrentQueue) DropLowestPriority(_ context.Context) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.queue) == 0 {
+		return Item{}, EmptyQueue
+	}
+	lowest, lowestPriority := 0, 0
+	for i, it := range q.queue {
+		ctx := it.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if p := taskPriority(ctx, it.Task); i == 0 || p <= lowestPriority {
+			lowest, lowestPriority = i, p
+		}
+	}
+	it := q.queue[lowest]
+	copy(q.queue[lowest:], q.queue[lowest+1:])
+	q.queue[len(q.queue)-1] = Item{}
+	q.queue = q.queue[:len(q.queue)-1]
 	return it, nil
 }
 
+// Remove takes task with given ID from queue including scheduled ones
+func (q *ConcurrentQueue) Remove(_ context.Context, id int64) (Item, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for i, it := range q.queue {
+		if it.ID == id {
+			copy(q.queue[i:], q.queue[i+1:])
+			q.queue[len(q.queue)-1] = Item{}
+			q.queue = q.queue[:len(q.queue)-1]
+			return it, nil
+		}
+	}
+	for _, j := range q.scheduled {
+		if j.ID == id {
+			heap.Remove(&q.scheduled, j.index)
+			return j.Item, nil
+		}
+	}
+	return Item{}, ErrTaskNotFound
+}
+
+// Len returns the number of tasks in queue including scheduled ones
 func (q *ConcurrentQueue) Len(_ context.Context) int {
-	return len(q.queue)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.queue) + len(q.scheduled)
 }
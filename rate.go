@@ -0,0 +1,189 @@
+package taskq
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit limits the rate of task starts with a token bucket
+type RateLimit struct {
+	// Rate is a number of task starts per second
+	Rate float64
+	// Burst is a number of tasks which can be started at once. Values less than 1 mean 1
+	Burst int
+}
+
+// TaskRateKey is an optional Task extension for tasks limited by TaskQ.KeyRateLimits,
+// e.g. tasks calling the same third-party API
+type TaskRateKey interface {
+	RateKey() string
+}
+
+// RateLimitStats is a state of rate limit token bucket
+type RateLimitStats struct {
+	Rate  float64
+	Burst int
+	// Tokens is a number of available tokens. Negative value is a number of tokens reserved by throttled tasks
+	Tokens float64
+}
+
+// tokenBucket reserves tokens in advance, so throttled tasks start in order of reservation
+type tokenBucket struct {
+	lock   sync.Mutex
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// refill adds tokens accumulated since the last call. Must be called under lock
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if burst := float64(b.limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+}
+
+// reserve takes a token and returns a delay after which the token is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.limit.Rate * float64(time.Second))
+}
+
+func (b *tokenBucket) stats() RateLimitStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	return RateLimitStats{Rate: b.limit.Rate, Burst: b.limit.Burst, Tokens: b.tokens}
+}
+
+// rateLimits keeps token buckets created from TaskQ.RateLimit and TaskQ.KeyRateLimits on first use
+type rateLimits struct {
+	lock   sync.Mutex
+	global *tokenBucket
+	keys   map[string]*tokenBucket
+	init   bool
+}
+
+// rateBuckets returns global bucket and bucket of `key`, nil if limit is not configured
+func (t *TaskQ) rateBuckets(key string, hasKey bool) (*tokenBucket, *tokenBucket) {
+	r := &t.rates
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.init {
+		r.init = true
+		if t.RateLimit != nil && t.RateLimit.Rate > 0 {
+			r.global = newTokenBucket(*t.RateLimit)
+		}
+		r.keys = make(map[string]*tokenBucket)
+	}
+	if !hasKey {
+		return r.global, nil
+	}
+	b, ok := r.keys[key]
+	if !ok {
+		if limit, ok := t.KeyRateLimits[key]; ok && limit.Rate > 0 {
+			b = newTokenBucket(limit)
+			r.keys[key] = b
+		}
+	}
+	return r.global, b
+}
+
+func rateKey(task Task) (key string, ok bool) {
+	findTask(task, func(task Task) bool {
+		rk, found := task.(TaskRateKey)
+		if found && rk != nil {
+			key, ok = rk.RateKey(), true
+		}
+		return found
+	})
+	return key, ok
+}
+
+// throttle delays job until rate limit tokens are available. Returns true if job was delayed.
+// Throttled job doesn't hold a worker and starts without checking limits again when it's due
+func (t *TaskQ) throttle(j *job) bool {
+	if j.admitted {
+		j.admitted = false
+		return false
+	}
+	delay := t.reserve(j)
+	if delay <= 0 {
+		return false
+	}
+	j.admitted = true
+	j.at = time.Now().Add(delay)
+	atomic.AddInt32(&t.pending, 1)
+	t.throttled.push(j)
+	return true
+}
+
+// reserve takes rate limit tokens for job and returns a delay after which they are available
+func (t *TaskQ) reserve(j *job) time.Duration {
+	if t.RateLimit == nil && len(t.KeyRateLimits) == 0 {
+		return 0
+	}
+	global, keyed := t.rateBuckets(rateKey(j.Task))
+	now := time.Now()
+	var delay time.Duration
+	for _, b := range []*tokenBucket{global, keyed} {
+		if b == nil {
+			continue
+		}
+		if d := b.reserve(now); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// rateStats returns states of rate limit buckets
+func (t *TaskQ) rateStats() (*RateLimitStats, map[string]RateLimitStats) {
+	r := &t.rates
+	r.lock.Lock()
+	if !r.init {
+		// buckets are not used yet
+		r.lock.Unlock()
+		if t.RateLimit == nil || t.RateLimit.Rate <= 0 {
+			return nil, nil
+		}
+		s := newTokenBucket(*t.RateLimit).stats()
+		return &s, nil
+	}
+	global := r.global
+	keys := make(map[string]*tokenBucket, len(r.keys))
+	for key, b := range r.keys {
+		keys[key] = b
+	}
+	r.lock.Unlock()
+	var gs *RateLimitStats
+	if global != nil {
+		s := global.stats()
+		gs = &s
+	}
+	var ks map[string]RateLimitStats
+	if len(keys) > 0 {
+		ks = make(map[string]RateLimitStats, len(keys))
+		for key, b := range keys {
+			ks[key] = b.stats()
+		}
+	}
+	return gs, ks
+}
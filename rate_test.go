@@ -0,0 +1,127 @@
+package taskq_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+type keyedTask struct {
+	key string
+	do  func()
+}
+
+func (t keyedTask) Do(ctx context.Context) error {
+	t.do()
+	return nil
+}
+
+func (t keyedTask) RateKey() string {
+	return t.key
+}
+
+func TestRateLimit_Global(t *testing.T) {
+	tq := taskq.New(4)
+	tq.RateLimit = &taskq.RateLimit{Rate: 20, Burst: 2}
+	tq.Start()
+	var lock sync.Mutex
+	var starts []time.Time
+	begin := time.Now()
+	for i := 0; i < 6; i++ {
+		tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+			lock.Lock()
+			starts = append(starts, time.Now())
+			lock.Unlock()
+			return nil
+		}))
+	}
+	waitFor(t, func() bool { return tq.Stats().Throttled > 0 })
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 6 {
+		t.Fatalf("expected 6 tasks, got %d", len(starts))
+	}
+	// burst of 2 and 4 tasks at 20 per second
+	if elapsed := starts[5].Sub(begin); elapsed < 180*time.Millisecond {
+		t.Fatalf("tasks started too fast: %s", elapsed)
+	}
+}
+
+func TestRateLimit_KeyDoesNotHoldWorker(t *testing.T) {
+	tq := taskq.New(1)
+	tq.KeyRateLimits = map[string]taskq.RateLimit{"api": {Rate: 1, Burst: 1}}
+	tq.Start()
+	defer tq.Close()
+	var keyed, other int32
+	for i := 0; i < 2; i++ {
+		tq.Enqueue(context.Background(), keyedTask{key: "api", do: func() { atomic.AddInt32(&keyed, 1) }})
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&keyed) == 1 && tq.Stats().Throttled == 1 })
+	tq.Enqueue(context.Background(), keyedTask{key: "other", do: func() { atomic.AddInt32(&other, 1) }})
+	waitFor(t, func() bool { return atomic.LoadInt32(&other) == 1 })
+	if n := atomic.LoadInt32(&keyed); n != 1 {
+		t.Fatalf("expected throttled keyed task, got %d runs", n)
+	}
+	st := tq.Stats()
+	if st.RateLimit != nil {
+		t.Fatalf("unexpected global rate limit: %+v", st.RateLimit)
+	}
+	api, ok := st.KeyRateLimits["api"]
+	if !ok || api.Rate != 1 || api.Tokens >= 0 {
+		t.Fatalf("unexpected key rate limit state: %+v", st.KeyRateLimits)
+	}
+}
+
+func TestRateLimit_ShutdownDropsThrottled(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RateLimit = &taskq.RateLimit{Rate: 0.1}
+	tq.Start()
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		tq.Enqueue(context.Background(), &testTask{
+			fOnError: func(ctx context.Context, err error) { errs <- err },
+		})
+	}
+	waitFor(t, func() bool { return tq.Stats().Throttled == 1 })
+	if err := tq.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != taskq.ErrClosed {
+			t.Fatalf("expected ErrClosed, got %v", err)
+		}
+	default:
+		t.Fatal("throttled task wasn't notified")
+	}
+}
+
+func TestRateLimit_CallerRunsWaits(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Capacity = 1
+	tq.OverflowPolicy = taskq.OverflowCallerRuns
+	tq.RateLimit = &taskq.RateLimit{Rate: 20, Burst: 1}
+	tq.Start()
+	release := make(chan struct{})
+	// first task takes the token and the worker, second one fills the queue
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+	waitFor(t, func() bool { return tq.Stats().Workers == 1 })
+	tq.Enqueue(context.Background(), noopTask())
+	begin := time.Now()
+	if _, err := tq.Enqueue(context.Background(), noopTask()); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if elapsed := time.Since(begin); elapsed < 40*time.Millisecond {
+		t.Fatalf("caller run ignored rate limit: %s", elapsed)
+	}
+	tq.Shutdown(taskq.ContextWithWait(context.Background()))
+}
@@ -0,0 +1,114 @@
+package taskq
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy describes re-scheduling of failed tasks.
+// Failed task doesn't hold a worker while waiting for the next attempt
+type RetryPolicy struct {
+	// MaxAttempts is a total number of task executions, including the first one
+	MaxAttempts int
+	// InitialInterval is a delay before the second attempt
+	InitialInterval time.Duration
+	// MaxInterval limits the delay between attempts. Zero means no limit
+	MaxInterval time.Duration
+	// Multiplier of delay for every next attempt: 2 for exponential backoff, 1 or 0 for constant
+	Multiplier float64
+	// Jitter randomizes delay in range [delay*(1-Jitter), delay*(1+Jitter)]. Valid values: [0, 1]
+	Jitter float64
+}
+
+// ConstantBackoff retries task every interval
+func ConstantBackoff(maxAttempts int, interval time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: interval,
+	}
+}
+
+// ExponentialBackoff doubles the delay after every attempt up to maxInterval
+func ExponentialBackoff(maxAttempts int, initial, maxInterval time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:     maxAttempts,
+		InitialInterval: initial,
+		MaxInterval:     maxInterval,
+		Multiplier:      2,
+	}
+}
+
+// Delay returns a delay before the next attempt after failed `attempt`
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	d := float64(p.InitialInterval)
+	if p.Multiplier > 1 && attempt > 1 {
+		d *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+	if p.MaxInterval > 0 && d > float64(p.MaxInterval) {
+		d = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+// TaskRetryPolicy overrides TaskQ.RetryPolicy for the task. Nil policy disables retries
+type TaskRetryPolicy interface {
+	RetryPolicy() *RetryPolicy
+}
+
+type ctxAttemptKey struct{}
+
+// Attempt returns the number of the current task execution starting from 1.
+// Returns 0 if ctx is not a task context
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(ctxAttemptKey{}).(int)
+	return attempt
+}
+
+func (t *TaskQ) retryPolicy(task Task) *RetryPolicy {
+	policy := t.RetryPolicy
+	findTask(task, func(task Task) bool {
+		rp, ok := task.(TaskRetryPolicy)
+		if ok && rp != nil {
+			policy = rp.RetryPolicy()
+		}
+		return ok
+	})
+	return policy
+}
+
+// retry schedules the next attempt of failed job.
+// Returns false if retries are exhausted or TaskQ is stopping
+func (t *TaskQ) retry(j *job, err error) bool {
+	p := t.retryPolicy(j.Task)
+	if p == nil || j.attempt >= p.MaxAttempts || err == ErrTaskCancelled ||
+		atomic.LoadInt32(&t.isStopped) == 1 || t.ctx.Err() != nil {
+		return false
+	}
+	j.err = err
+	delay := p.Delay(j.attempt)
+	j.at = time.Now().Add(delay)
+	j.attempt++
+	t.setStatus(j, StateRetrying, err)
+	atomic.AddInt64(&t.counters.retried, 1)
+	atomic.AddInt32(&t.pending, 1)
+	// delivery is returned into queue, so retry survives restart.
+	// Retries of partition tasks are kept in memory for keeping partition order
+	if rd, ok := j.delivery.(RetryDelivery); ok && rd != nil && partitionKey(j.Task) == "" {
+		t.ackError(j, rd.Retry(context.Background(), delay))
+		j.stopLease()
+		// job is kept until retry is due for reporting it on shutdown, delivery is already settled
+		j.delivery = nil
+		// wakeup is not earlier than due time of delivery in queue
+		j.at = time.Now().Add(delay)
+		t.requeued.push(j)
+		return true
+	}
+	t.delayed.push(j)
+	return true
+}
@@ -0,0 +1,136 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+type retryTask struct {
+	testTask
+	policy *taskq.RetryPolicy
+}
+
+func (t *retryTask) RetryPolicy() *taskq.RetryPolicy {
+	return t.policy
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	var attempts []int
+	var lock sync.Mutex
+	done := make(chan struct{})
+	tt := &retryTask{
+		testTask: testTask{
+			fdo: func(ctx context.Context) error {
+				lock.Lock()
+				defer lock.Unlock()
+				attempts = append(attempts, taskq.Attempt(ctx))
+				if len(attempts) < 3 {
+					return errors.New("failed")
+				}
+				return nil
+			},
+			fOnError: func(ctx context.Context, err error) {
+				t.Errorf("unexpected OnError: %s", err)
+			},
+			fdone: func(ctx context.Context) {
+				close(done)
+			},
+		},
+		policy: taskq.ConstantBackoff(5, time.Millisecond),
+	}
+	tq := taskq.New(1)
+	tq.Start()
+	tq.Enqueue(context.Background(), tt)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task not finished")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[1] != 2 || attempts[2] != 3 {
+		t.Fatalf("invalid attempts: %v", attempts)
+	}
+	if s := tq.Stats(); s.Retried != 2 || s.Succeeded != 1 || s.Failed != 0 {
+		t.Fatalf("invalid stats: %+v", s)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	rch := make(chan int)
+	expected := errors.New("failed")
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ExponentialBackoff(3, time.Millisecond, 2*time.Millisecond)
+	tq.Start()
+	tq.Enqueue(context.Background(), &testTask{
+		fdo: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			return expected
+		},
+		fOnError: func(ctx context.Context, err error) {
+			if err != expected {
+				t.Errorf("invalid error. expected=%s got=%s", expected, err)
+			}
+			rch <- taskq.Attempt(ctx)
+		},
+	})
+	select {
+	case attempt := <-rch:
+		if attempt != 3 || atomic.LoadInt32(&calls) != 3 {
+			t.Fatalf("invalid number of attempts. expected=3 got=%d", attempt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnError not invoked")
+	}
+}
+
+func TestRetryDoesNotHoldWorker(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ConstantBackoff(2, 50*time.Millisecond)
+	tq.Start()
+	order := make(chan string, 3)
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		order <- "a"
+		if taskq.Attempt(ctx) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		order <- "b"
+		return nil
+	}))
+	if err := tq.Shutdown(taskq.ContextWithWait(context.Background())); err != nil {
+		t.Fatal("shutdown:", err)
+	}
+	close(order)
+	var result string
+	for s := range order {
+		result += s
+	}
+	if result != "aba" {
+		t.Fatalf("invalid order of execution. expected=aba got=%s", result)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := taskq.ExponentialBackoff(10, time.Second, 5*time.Second)
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if d := p.Delay(attempt + 1); d != expected {
+			t.Fatalf("invalid delay of attempt %d. expected=%s got=%s", attempt+1, expected, d)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay out of jitter range: %s", d)
+		}
+	}
+}
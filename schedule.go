@@ -0,0 +1,57 @@
+package taskq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrScheduleNotSupported is returned by EnqueueAt if queue doesn't implement ScheduledQueue
+var ErrScheduleNotSupported = errors.New("queue doesn't support scheduling")
+
+// EnqueueAt adds task to queue for execution not earlier than `at`.
+// Queue must implement ScheduledQueue
+func (t *TaskQ) EnqueueAt(ctx context.Context, task Task, at time.Time) (int64, error) {
+	if task == nil {
+		return -1, ErrNilTask
+	}
+
+	if atomic.LoadInt32(&t.isClosed) != 0 {
+		return -1, ErrClosed
+	}
+
+	q, ok := t.queue.(ScheduledQueue)
+	if !ok {
+		return -1, ErrScheduleNotSupported
+	}
+	now := time.Now()
+	id, err := t.push(ctx, func() (int64, error) {
+		return q.EnqueueAt(ctx, task, at)
+	})
+	// scheduled task can't run in caller goroutine before it's due, it's rejected as with OverflowReject
+	if err == errCallerRuns {
+		return -1, ErrQueueFull
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	status := TaskStatus{ID: id, State: StatePending, EnqueuedAt: now, DueAt: at, UpdatedAt: now}
+	if at.After(now) {
+		status.State = StateScheduled
+	}
+	t.saveStatus(status)
+	if at.After(time.Now()) {
+		// wake up worker when task is due
+		t.wakeups.push(&job{at: at})
+	} else {
+		t.notify()
+	}
+	return id, nil
+}
+
+// EnqueueAfter adds task to queue for execution after delay `d`
+func (t *TaskQ) EnqueueAfter(ctx context.Context, task Task, d time.Duration) (int64, error) {
+	return t.EnqueueAt(ctx, task, time.Now().Add(d))
+}
@@ -0,0 +1,61 @@
+package taskq_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestEnqueueAfter_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	rch := make(chan time.Time)
+	start := time.Now()
+	const delay = 30 * time.Millisecond
+	_, err := tq.EnqueueAfter(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		rch <- time.Now()
+		return nil
+	}), delay)
+	if err != nil {
+		t.Fatal("enqueue after:", err)
+	}
+	select {
+	case at := <-rch:
+		if at.Sub(start) < delay {
+			t.Fatalf("task executed too early: %s", at.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("scheduled task not executed")
+	}
+}
+
+func TestEnqueueAtNotSupported_Err(t *testing.T) {
+	tq := taskq.NewWithQueue(0, &testQueue{})
+	_, err := tq.EnqueueAt(context.Background(), taskq.TaskFunc(func(ctx context.Context) error { return nil }), time.Now())
+	if err != taskq.ErrScheduleNotSupported {
+		t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrScheduleNotSupported, err)
+	}
+}
+
+func TestConcurrentQueueScheduledOrder(t *testing.T) {
+	q := taskq.NewConcurrentQueue()
+	now := time.Now()
+	id2, _ := q.EnqueueAt(context.Background(), &testTask{}, now.Add(20*time.Millisecond))
+	id1, _ := q.EnqueueAt(context.Background(), &testTask{}, now.Add(10*time.Millisecond))
+	id0, _ := q.Enqueue(context.Background(), &testTask{})
+	if it, err := q.DequeueItem(context.Background()); err != nil || it.ID != id0 {
+		t.Fatalf("invalid item. expected=%d got=%d err=%v", id0, it.ID, err)
+	}
+	if _, err := q.DequeueItem(context.Background()); err != taskq.EmptyQueue {
+		t.Fatalf("scheduled task dequeued before due time. err=%v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	for _, expected := range []int64{id1, id2} {
+		it, err := q.DequeueItem(context.Background())
+		if err != nil || it.ID != expected {
+			t.Fatalf("invalid item. expected=%d got=%d err=%v", expected, it.ID, err)
+		}
+	}
+}
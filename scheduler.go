@@ -0,0 +1,330 @@
+package taskq
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoActivations    = errors.New("schedule has no activations")
+	ErrSchedulerStarted = errors.New("scheduler started")
+)
+
+// MisfirePolicy decides what to do with activations missed by more than Scheduler.MisfireThreshold
+type MisfirePolicy int
+
+const (
+	// MisfireRunOnce runs all missed activations as a single run
+	MisfireRunOnce MisfirePolicy = iota
+	// MisfireSkip drops missed activations and waits for the next one
+	MisfireSkip
+	// MisfireCatchUp runs every missed activation
+	MisfireCatchUp
+)
+
+// OverlapPolicy decides whether activation may start while the previous run is still active
+type OverlapPolicy int
+
+const (
+	// OverlapAllow runs activations concurrently
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip drops activation if the previous run is active
+	OverlapSkip
+	// OverlapDelay starts activation right after the previous run is finished
+	OverlapDelay
+)
+
+// Periodic is a recurring task
+type Periodic struct {
+	Task     Task
+	Schedule Schedule
+	// Jitter delays every activation by random duration in range [0, Jitter)
+	Jitter  time.Duration
+	Misfire MisfirePolicy
+	Overlap OverlapPolicy
+}
+
+type periodicEntry struct {
+	id int64
+	Periodic
+	// next is a planned activation time, fireAt is next with jitter
+	next   time.Time
+	fireAt time.Time
+	index  int // index in periodicHeap, -1 if entry is not scheduled
+	// number of active runs
+	running int
+	// activation delayed by OverlapDelay
+	pending bool
+	removed bool
+}
+
+type periodicHeap []*periodicEntry
+
+func (h periodicHeap) Len() int           { return len(h) }
+func (h periodicHeap) Less(i, j int) bool { return h[i].fireAt.Before(h[j].fireAt) }
+func (h periodicHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *periodicHeap) Push(x interface{}) {
+	e := x.(*periodicEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *periodicHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// Scheduler enqueues periodic tasks into TaskQ according to their schedules.
+// Every activation is an ordinary task, so TaskQ limits and shutdown apply to it
+type Scheduler struct {
+	tq *TaskQ
+
+	lock      sync.Mutex
+	lastID    int64
+	entries   map[int64]*periodicEntry
+	queue     periodicHeap
+	timer     *time.Timer
+	isRunning bool
+
+	// MisfireThreshold is the lateness after which activation is treated as missed
+	MisfireThreshold time.Duration
+	OnEnqueueError   func(ctx context.Context, periodicID int64, err error)
+}
+
+func NewScheduler(tq *TaskQ) *Scheduler {
+	return &Scheduler{
+		tq:               tq,
+		entries:          make(map[int64]*periodicEntry),
+		MisfireThreshold: time.Second,
+	}
+}
+
+// Add registers periodic task and returns its ID
+func (s *Scheduler) Add(p Periodic) (int64, error) {
+	if p.Task == nil {
+		return -1, ErrNilTask
+	}
+	next := p.Schedule.Next(time.Now())
+	if next.IsZero() {
+		return -1, ErrNoActivations
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastID++
+	e := &periodicEntry{id: s.lastID, Periodic: p, index: -1}
+	s.entries[e.id] = e
+	s.schedule(e, next)
+	return e.id, nil
+}
+
+// Remove unregisters periodic task. Active runs are not interrupted
+func (s *Scheduler) Remove(id int64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return false
+	}
+	delete(s.entries, id)
+	e.removed = true
+	e.pending = false
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+	return true
+}
+
+func (s *Scheduler) Start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.isRunning {
+		return ErrSchedulerStarted
+	}
+	s.isRunning = true
+	s.resetTimer()
+	return nil
+}
+
+// Stop stops activations of periodic tasks. Already enqueued runs are not affected
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.isRunning = false
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// schedule puts entry into queue with the planned activation time. Must be called under lock
+func (s *Scheduler) schedule(e *periodicEntry, next time.Time) {
+	e.next = next
+	e.fireAt = next
+	if e.Jitter > 0 {
+		e.fireAt = next.Add(time.Duration(rand.Int63n(int64(e.Jitter))))
+	}
+	heap.Push(&s.queue, e)
+	if e.index == 0 {
+		s.resetTimer()
+	}
+}
+
+// resetTimer sets timer for the earliest activation. Must be called under lock
+func (s *Scheduler) resetTimer() {
+	if !s.isRunning || len(s.queue) == 0 {
+		return
+	}
+	d := time.Until(s.queue[0].fireAt)
+	if s.timer == nil {
+		s.timer = time.AfterFunc(d, s.fire)
+		return
+	}
+	s.timer.Stop()
+	s.timer.Reset(d)
+}
+
+func (s *Scheduler) fire() {
+	var runs []*periodicEntry
+	s.lock.Lock()
+	if !s.isRunning {
+		s.lock.Unlock()
+		return
+	}
+	now := time.Now()
+	for len(s.queue) > 0 && !s.queue[0].fireAt.After(now) {
+		e := heap.Pop(&s.queue).(*periodicEntry)
+		count, next := s.activations(e, now)
+		for i := 0; i < count; i++ {
+			if s.overlap(e) {
+				e.running++
+				runs = append(runs, e)
+			}
+		}
+		if _, ok := e.Schedule.(fixedDelay); ok {
+			continue // rescheduled when run is finished
+		}
+		if !next.IsZero() {
+			s.schedule(e, next)
+		}
+	}
+	s.resetTimer()
+	s.lock.Unlock()
+
+	for _, e := range runs {
+		s.enqueue(e)
+	}
+}
+
+// activations returns the number of runs of due entry according to its misfire policy
+// and the next planned activation after `now`
+func (s *Scheduler) activations(e *periodicEntry, now time.Time) (int, time.Time) {
+	if _, ok := e.Schedule.(fixedDelay); ok {
+		return 1, time.Time{}
+	}
+	var missed, onTime int
+	for t := e.next; !t.IsZero() && !t.After(now); t = e.Schedule.Next(t) {
+		if now.Sub(t) <= s.MisfireThreshold {
+			onTime++
+		} else if r, ok := e.Schedule.(fixedRate); ok && e.Misfire != MisfireCatchUp {
+			// skip missed activations without iterating over them
+			k := (now.Sub(t) - s.MisfireThreshold) / time.Duration(r)
+			missed += int(k) + 1
+			t = t.Add(k * time.Duration(r))
+		} else {
+			missed++
+		}
+		e.next = t
+	}
+	next := e.Schedule.Next(e.next)
+	switch {
+	case missed == 0, e.Misfire == MisfireSkip:
+		return onTime, next
+	case e.Misfire == MisfireCatchUp:
+		return missed + onTime, next
+	default:
+		return 1, next
+	}
+}
+
+// overlap checks whether a new run can be started. Must be called under lock
+func (s *Scheduler) overlap(e *periodicEntry) bool {
+	if e.running == 0 || e.Overlap == OverlapAllow {
+		return true
+	}
+	if e.Overlap == OverlapDelay {
+		e.pending = true
+	}
+	return false
+}
+
+func (s *Scheduler) enqueue(e *periodicEntry) {
+	_, err := s.tq.Enqueue(context.Background(), &periodicRun{s: s, e: e})
+	if err == nil {
+		return
+	}
+	if err == ErrClosed {
+		s.Stop()
+	}
+	s.finished(e)
+	if s.OnEnqueueError != nil {
+		s.OnEnqueueError(context.Background(), e.id, err)
+	}
+}
+
+func (s *Scheduler) finished(e *periodicEntry) {
+	s.lock.Lock()
+	e.running--
+	if _, ok := e.Schedule.(fixedDelay); ok && !e.removed && e.index < 0 {
+		if next := e.Schedule.Next(time.Now()); !next.IsZero() {
+			s.schedule(e, next)
+		}
+	}
+	run := e.pending && s.isRunning
+	if run {
+		e.pending = false
+		e.running++
+	}
+	s.lock.Unlock()
+	if run {
+		s.enqueue(e)
+	}
+}
+
+// periodicRun is a single activation of periodic task
+type periodicRun struct {
+	s *Scheduler
+	e *periodicEntry
+}
+
+func (r *periodicRun) Unwrap() Task {
+	return r.e.Task
+}
+
+func (r *periodicRun) Do(ctx context.Context) error {
+	return r.e.Task.Do(ctx)
+}
+
+func (r *periodicRun) Done(ctx context.Context) {
+	r.s.finished(r.e)
+	if event, ok := r.e.Task.(TaskDone); ok && event != nil {
+		event.Done(ctx)
+	}
+}
+
+func (r *periodicRun) OnError(ctx context.Context, err error) {
+	r.s.finished(r.e)
+	taskOnError(ctx, r.e.Task, err)
+}
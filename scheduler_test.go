@@ -0,0 +1,120 @@
+package taskq_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func TestSchedulerFixedRate_Ok(t *testing.T) {
+	tq := taskq.New(0)
+	tq.Start()
+	s := taskq.NewScheduler(tq)
+	var runs int32
+	_, err := s.Add(taskq.Periodic{
+		Task: taskq.TaskFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		}),
+		Schedule: taskq.FixedRate(10 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal("add:", err)
+	}
+	s.Start()
+	time.Sleep(105 * time.Millisecond)
+	s.Stop()
+	if r := atomic.LoadInt32(&runs); r < 5 || r > 11 {
+		t.Fatalf("invalid number of runs: %d", r)
+	}
+}
+
+func TestSchedulerOverlapSkip_Ok(t *testing.T) {
+	tq := taskq.New(0)
+	tq.Start()
+	s := taskq.NewScheduler(tq)
+	var runs, active, maxActive int32
+	s.Add(taskq.Periodic{
+		Task: taskq.TaskFunc(func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			if a := atomic.AddInt32(&active, 1); a > atomic.LoadInt32(&maxActive) {
+				atomic.StoreInt32(&maxActive, a)
+			}
+			time.Sleep(35 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return nil
+		}),
+		Schedule: taskq.FixedRate(10 * time.Millisecond),
+		Overlap:  taskq.OverlapSkip,
+	})
+	s.Start()
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+	if m := atomic.LoadInt32(&maxActive); m != 1 {
+		t.Fatalf("runs overlapped: %d", m)
+	}
+	if r := atomic.LoadInt32(&runs); r > 4 {
+		t.Fatalf("overlapping runs were not skipped: %d", r)
+	}
+}
+
+func TestSchedulerMisfire(t *testing.T) {
+	for _, tc := range []struct {
+		policy   taskq.MisfirePolicy
+		min, max int32
+	}{
+		{taskq.MisfireSkip, 0, 0},
+		{taskq.MisfireRunOnce, 1, 1},
+		{taskq.MisfireCatchUp, 4, 6},
+	} {
+		tq := taskq.New(0)
+		tq.Start()
+		s := taskq.NewScheduler(tq)
+		s.MisfireThreshold = time.Millisecond
+		var runs int32
+		s.Add(taskq.Periodic{
+			Task: taskq.TaskFunc(func(ctx context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			}),
+			Schedule: taskq.FixedRate(50 * time.Millisecond),
+			Misfire:  tc.policy,
+		})
+		// activations are missed while scheduler is not running
+		time.Sleep(270 * time.Millisecond)
+		s.Start()
+		time.Sleep(10 * time.Millisecond)
+		s.Stop()
+		tq.Shutdown(taskq.ContextWithWait(context.Background()))
+		if r := atomic.LoadInt32(&runs); r < tc.min || r > tc.max {
+			t.Fatalf("invalid number of runs for policy %d. expected=[%d, %d] got=%d", tc.policy, tc.min, tc.max, r)
+		}
+	}
+}
+
+func TestSchedulerStopsOnClosedTaskq(t *testing.T) {
+	tq := taskq.New(0)
+	tq.Start()
+	tq.Close()
+	s := taskq.NewScheduler(tq)
+	errs := make(chan error, 1)
+	s.OnEnqueueError = func(ctx context.Context, id int64, err error) {
+		errs <- err
+	}
+	s.Add(taskq.Periodic{
+		Task:     taskq.TaskFunc(func(ctx context.Context) error { return nil }),
+		Schedule: taskq.FixedDelay(time.Millisecond),
+	})
+	s.Start()
+	select {
+	case err := <-errs:
+		if err != taskq.ErrClosed {
+			t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrClosed, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("enqueue error not reported")
+	}
+}
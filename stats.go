@@ -0,0 +1,89 @@
+package taskq
+
+import "sync/atomic"
+
+// Stats is a snapshot of TaskQ counters
+type Stats struct {
+	// Workers is a number of active workers including idle ones
+	Workers int
+	// Idle is a number of workers waiting for new tasks
+	Idle int
+	// Limit is the maximum number of workers
+	Limit int
+	// ConcurrencyLimit is the effective limit of running tasks set by ConcurrencyLimiter
+	ConcurrencyLimit int
+	// Succeeded is a number of tasks finished without error
+	Succeeded int64
+	// Failed is a number of tasks finished with error, including panicked and timed out tasks
+	Failed   int64
+	Panicked int64
+	TimedOut int64
+	// Retried is a number of scheduled retries
+	Retried int64
+	// Dropped is a number of tasks removed from queue by overflow policy
+	Dropped int64
+	// Cancelled is a number of tasks cancelled by TaskQ.Cancel. They are not counted as failed
+	Cancelled int64
+	// DeadLettered is a number of tasks moved to TaskQ.DeadLetter
+	DeadLettered int64
+	// Throttled is a number of tasks waiting for rate limit tokens
+	Throttled int
+	// PartitionWaiting is a number of tasks waiting for their partition, see TaskPartition
+	PartitionWaiting int
+	// RateLimit is a state of TaskQ.RateLimit, nil if it's not set
+	RateLimit *RateLimitStats
+	// KeyRateLimits are states of TaskQ.KeyRateLimits of keys used by tasks
+	KeyRateLimits map[string]RateLimitStats
+}
+
+// counters must be the first field of TaskQ for 64-bit alignment of atomic operations
+type counters struct {
+	succeeded    int64
+	failed       int64
+	panicked     int64
+	timedOut     int64
+	retried      int64
+	dropped      int64
+	deadLettered int64
+	cancelled    int64
+}
+
+func (c *counters) add(err error) {
+	if err == nil {
+		atomic.AddInt64(&c.succeeded, 1)
+		return
+	}
+	if err == ErrTaskCancelled {
+		atomic.AddInt64(&c.cancelled, 1)
+		return
+	}
+	atomic.AddInt64(&c.failed, 1)
+	switch err.(type) {
+	case *PanicError:
+		atomic.AddInt64(&c.panicked, 1)
+	case *TimeoutError:
+		atomic.AddInt64(&c.timedOut, 1)
+	}
+}
+
+func (t *TaskQ) Stats() Stats {
+	rate, keyRates := t.rateStats()
+	return Stats{
+		Workers:          int(atomic.LoadInt32(&t.workerCount)),
+		Idle:             int(atomic.LoadInt32(&t.idle)),
+		Limit:            t.pool.size(),
+		ConcurrencyLimit: t.concurrencyLimit(),
+		Succeeded:        atomic.LoadInt64(&t.counters.succeeded),
+		Failed:           atomic.LoadInt64(&t.counters.failed),
+		Panicked:         atomic.LoadInt64(&t.counters.panicked),
+		TimedOut:         atomic.LoadInt64(&t.counters.timedOut),
+		Retried:          atomic.LoadInt64(&t.counters.retried),
+		Dropped:          atomic.LoadInt64(&t.counters.dropped),
+		DeadLettered:     atomic.LoadInt64(&t.counters.deadLettered),
+		Cancelled:        atomic.LoadInt64(&t.counters.cancelled),
+		Throttled:        t.throttled.len(),
+		PartitionWaiting: t.partitions.len(),
+		RateLimit:        rate,
+		KeyRateLimits:    keyRates,
+	}
+}
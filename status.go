@@ -0,0 +1,183 @@
+package taskq
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTaskNotFound is returned by TaskQ.Status for unknown task
+var ErrTaskNotFound = errors.New("task not found")
+
+// DefaultStatusTrackerSize is a size of StatusTracker created by New
+const DefaultStatusTrackerSize = 1024
+
+// TaskState is a lifecycle state of a task
+type TaskState int
+
+const (
+	StatePending TaskState = iota + 1
+	StateScheduled
+	StateRunning
+	StateSucceeded
+	StateFailed
+	StateRetrying
+	StateCancelled
+	StateDeadLettered
+)
+
+var stateNames = map[TaskState]string{
+	StatePending:      "pending",
+	StateScheduled:    "scheduled",
+	StateRunning:      "running",
+	StateSucceeded:    "succeeded",
+	StateFailed:       "failed",
+	StateRetrying:     "retrying",
+	StateCancelled:    "cancelled",
+	StateDeadLettered: "dead-lettered",
+}
+
+func (s TaskState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Finished returns true for terminal states
+func (s TaskState) Finished() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateCancelled, StateDeadLettered:
+		return true
+	}
+	return false
+}
+
+// TaskStatus is a state of a task with its timestamps
+type TaskStatus struct {
+	ID      int64
+	State   TaskState
+	Attempt int
+	// Err is the last error of task
+	Err        error
+	EnqueuedAt time.Time
+	StartedAt  time.Time
+	// DueAt is a time of scheduled start or next attempt
+	DueAt     time.Time
+	UpdatedAt time.Time
+}
+
+// StatusStore keeps task statuses. Statuses of tasks enqueued by other processes
+// can be shared through a persistent store
+type StatusStore interface {
+	// SetStatus saves status. Status older than the saved one by UpdatedAt must be ignored
+	SetStatus(ctx context.Context, s TaskStatus) error
+	// Status returns ErrTaskNotFound for unknown task
+	Status(ctx context.Context, id int64) (TaskStatus, error)
+}
+
+// StatusTracker is an in-memory StatusStore keeping at most `size` statuses.
+// Statuses of finished tasks are evicted first in order of finishing, then statuses of the oldest active tasks
+type StatusTracker struct {
+	lock     sync.Mutex
+	size     int
+	statuses map[int64]*list.Element
+	// active and finished are statuses in order of adding to the list
+	active   list.List
+	finished list.List
+}
+
+func NewStatusTracker(size int) *StatusTracker {
+	return &StatusTracker{
+		size:     size,
+		statuses: make(map[int64]*list.Element),
+	}
+}
+
+func (s *StatusTracker) list(status TaskStatus) *list.List {
+	if status.State.Finished() {
+		return &s.finished
+	}
+	return &s.active
+}
+
+func (s *StatusTracker) SetStatus(_ context.Context, status TaskStatus) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e, ok := s.statuses[status.ID]; ok {
+		old := e.Value.(TaskStatus)
+		if status.UpdatedAt.Before(old.UpdatedAt) {
+			return nil
+		}
+		if old.State.Finished() == status.State.Finished() {
+			e.Value = status
+			return nil
+		}
+		s.list(old).Remove(e)
+	}
+	s.statuses[status.ID] = s.list(status).PushBack(status)
+	for len(s.statuses) > s.size {
+		l := &s.finished
+		if l.Len() == 0 {
+			l = &s.active
+		}
+		delete(s.statuses, l.Remove(l.Front()).(TaskStatus).ID)
+	}
+	return nil
+}
+
+func (s *StatusTracker) Status(_ context.Context, id int64) (TaskStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e, ok := s.statuses[id]
+	if !ok {
+		return TaskStatus{}, ErrTaskNotFound
+	}
+	return e.Value.(TaskStatus), nil
+}
+
+// Status returns the current state of task by ID returned from Enqueue.
+// Tasks are tracked only if Queue implements ItemQueue or AckQueue, other queues don't return IDs of dequeued tasks
+func (t *TaskQ) Status(ctx context.Context, id int64) (TaskStatus, error) {
+	if t.StatusStore == nil {
+		return TaskStatus{}, ErrTaskNotFound
+	}
+	return t.StatusStore.Status(ctx, id)
+}
+
+// saveStatus saves status of task with known ID. Store errors are ignored
+func (t *TaskQ) saveStatus(s TaskStatus) {
+	if t.StatusStore == nil || s.ID <= 0 || !t.tracksStatus() {
+		return
+	}
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = time.Now()
+	}
+	t.StatusStore.SetStatus(context.Background(), s)
+}
+
+// tracksStatus returns true if queue returns IDs of dequeued tasks, so their statuses are updated
+func (t *TaskQ) tracksStatus() bool {
+	switch t.queue.(type) {
+	case ItemQueue, AckQueue:
+		return true
+	}
+	return false
+}
+
+func (t *TaskQ) setStatus(j *job, state TaskState, err error) {
+	s := TaskStatus{
+		ID:         j.ID,
+		State:      state,
+		Attempt:    j.attempt,
+		Err:        err,
+		EnqueuedAt: j.EnqueuedAt,
+		StartedAt:  j.started,
+	}
+	if state == StateRetrying {
+		s.DueAt = j.at
+	}
+	t.saveStatus(s)
+}
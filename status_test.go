@@ -0,0 +1,117 @@
+package taskq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antonmashko/taskq"
+)
+
+func taskState(t *testing.T, tq *taskq.TaskQ, id int64) taskq.TaskState {
+	t.Helper()
+	s, err := tq.Status(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.State
+}
+
+func TestStatus_Lifecycle(t *testing.T) {
+	tq := taskq.New(1)
+	release := make(chan struct{})
+	id, _ := tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+	if s := taskState(t, tq, id); s != taskq.StatePending {
+		t.Fatalf("invalid state. expected=%s got=%s", taskq.StatePending, s)
+	}
+	tq.Start()
+	defer tq.Close()
+	waitFor(t, func() bool { return taskState(t, tq, id) == taskq.StateRunning })
+	close(release)
+	waitFor(t, func() bool { return taskState(t, tq, id) == taskq.StateSucceeded })
+	s, _ := tq.Status(context.Background(), id)
+	if s.EnqueuedAt.IsZero() || s.StartedAt.Before(s.EnqueuedAt) || s.UpdatedAt.Before(s.StartedAt) {
+		t.Fatalf("invalid timestamps: %+v", s)
+	}
+}
+
+func TestStatus_RetryingAndFailed(t *testing.T) {
+	tq := taskq.New(1)
+	tq.RetryPolicy = taskq.ConstantBackoff(2, 50*time.Millisecond)
+	tq.Start()
+	defer tq.Close()
+	testErr := errors.New("test")
+	id, _ := tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		return testErr
+	}))
+	waitFor(t, func() bool { return taskState(t, tq, id) == taskq.StateRetrying })
+	waitFor(t, func() bool { return taskState(t, tq, id) == taskq.StateFailed })
+	s, _ := tq.Status(context.Background(), id)
+	if s.Err != testErr || s.Attempt != 2 {
+		t.Fatalf("invalid status: %+v", s)
+	}
+}
+
+func TestStatus_Scheduled(t *testing.T) {
+	tq := taskq.New(1)
+	tq.Start()
+	defer tq.Close()
+	at := time.Now().Add(time.Hour)
+	id, _ := tq.EnqueueAt(context.Background(), noopTask(), at)
+	s, err := tq.Status(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State != taskq.StateScheduled || !s.DueAt.Equal(at) {
+		t.Fatalf("invalid status: %+v", s)
+	}
+	if _, err := tq.Status(context.Background(), id+1); err != taskq.ErrTaskNotFound {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrTaskNotFound, err)
+	}
+}
+
+func TestStatusTracker_Eviction(t *testing.T) {
+	ctx := context.Background()
+	tr := taskq.NewStatusTracker(2)
+	tr.SetStatus(ctx, taskq.TaskStatus{ID: 1, State: taskq.StateRunning})
+	for id := int64(2); id <= 4; id++ {
+		tr.SetStatus(ctx, taskq.TaskStatus{ID: id, State: taskq.StateSucceeded})
+	}
+	// active task is kept, the oldest finished are evicted
+	for id, found := range map[int64]bool{1: true, 2: false, 3: false, 4: true} {
+		if _, err := tr.Status(ctx, id); (err == nil) != found {
+			t.Fatalf("invalid status of %d. found=%v err=%v", id, found, err)
+		}
+	}
+	// the oldest active task is evicted when there are no finished ones
+	tr.SetStatus(ctx, taskq.TaskStatus{ID: 6, State: taskq.StatePending})
+	tr.SetStatus(ctx, taskq.TaskStatus{ID: 7, State: taskq.StatePending})
+	for id, found := range map[int64]bool{1: false, 4: false, 6: true, 7: true} {
+		if _, err := tr.Status(ctx, id); (err == nil) != found {
+			t.Fatalf("invalid status of %d. found=%v err=%v", id, found, err)
+		}
+	}
+	// outdated status is ignored
+	now := time.Now()
+	tr.SetStatus(ctx, taskq.TaskStatus{ID: 5, State: taskq.StateRunning, UpdatedAt: now})
+	tr.SetStatus(ctx, taskq.TaskStatus{ID: 5, State: taskq.StatePending, UpdatedAt: now.Add(-time.Second)})
+	if s, _ := tr.Status(ctx, 5); s.State != taskq.StateRunning {
+		t.Fatalf("invalid state. expected=%s got=%s", taskq.StateRunning, s.State)
+	}
+}
+
+func TestStatus_NotTrackedWithoutItemQueue(t *testing.T) {
+	tq := taskq.NewWithQueue(1, &testQueue{})
+	id, err := tq.Enqueue(context.Background(), noopTask())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// plain queue doesn't return IDs of dequeued tasks, so status would never be updated
+	if _, err := tq.Status(context.Background(), id); err != taskq.ErrTaskNotFound {
+		t.Fatalf("invalid error. expected=%s got=%v", taskq.ErrTaskNotFound, err)
+	}
+}
@@ -2,8 +2,15 @@ package taskq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
 )
 
+// ErrTaskTimeout matches (errors.Is) TimeoutError of a task
+var ErrTaskTimeout = errors.New("task timeout")
+
 // Task for TaskQ
 type Task interface {
 	Do(ctx context.Context) error
@@ -17,22 +24,156 @@ type TaskOnError interface {
 	OnError(context.Context, error)
 }
 
+// TaskOnPanic is invoked when Task.Do panics.
+// If task doesn't implement TaskOnPanic, PanicError is passed to TaskOnError
+type TaskOnPanic interface {
+	OnPanic(context.Context, *PanicError)
+}
+
+// TaskTimeout limits execution time of Task.Do. Non-positive value disables TaskQ.DefaultTimeout
+type TaskTimeout interface {
+	Timeout() time.Duration
+}
+
 type TaskFunc func(ctx context.Context) error
 
 func (t TaskFunc) Do(ctx context.Context) error {
 	return t(ctx)
 }
 
-func processTask(ctx context.Context, task Task) {
-	err := task.Do(ctx)
+// PanicError is a recovered panic of Task.Do with the stack trace of the panicking goroutine
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panic: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutError is returned when Task.Do failed after its timeout expired
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("task timeout %s: %s", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTaskTimeout
+}
+
+// taskWrapper is implemented by tasks created by TaskQ components around user tasks.
+// Optional task interfaces (TaskTimeout, TaskRetryPolicy etc.) are looked up through Unwrap
+type taskWrapper interface {
+	Unwrap() Task
+}
+
+// findTask walks through wrapped tasks until `f` returns true
+func findTask(task Task, f func(Task) bool) bool {
+	for task != nil {
+		if f(task) {
+			return true
+		}
+		w, ok := task.(taskWrapper)
+		if !ok {
+			return false
+		}
+		task = w.Unwrap()
+	}
+	return false
+}
+
+func (t *TaskQ) taskTimeout(task Task) time.Duration {
+	timeout := t.DefaultTimeout
+	findTask(task, func(task Task) bool {
+		tt, ok := task.(TaskTimeout)
+		if ok && tt != nil {
+			timeout = tt.Timeout()
+		}
+		return ok
+	})
+	return timeout
+}
+
+func (t *TaskQ) doTask(ctx context.Context, task Task) (err error) {
+	if timeout := t.taskTimeout(task); timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer func() {
+			if _, ok := err.(*PanicError); !ok && err != nil &&
+				ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				err = &TimeoutError{Timeout: timeout, Err: err}
+			}
+			cancel()
+		}()
+	}
+	if !t.CrashOnPanic {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	return task.Do(ctx)
+}
+
+func (t *TaskQ) processTask(ctx context.Context, j *job) {
+	task := j.Task
+	start := time.Now()
+	err := t.doTask(ctx, task)
+	if err != nil && Cause(ctx) == ErrTaskCancelled {
+		err = ErrTaskCancelled
+	}
+	if t.ConcurrencyLimiter != nil {
+		t.observe(time.Since(start), err)
+	}
+	if err != nil && t.retry(j, err) {
+		return
+	}
+	t.counters.add(err)
+	// next job of partition starts after events of this one
+	defer t.leavePartition(j)
+	defer t.settle(j, err)
 	if err != nil {
-		if event, ok := task.(TaskOnError); ok && event != nil {
-			event.OnError(ctx, err)
+		state := StateFailed
+		// task interrupted by Shutdown is not a failure of the task
+		if t.ctx.Err() != nil || err == ErrTaskCancelled {
+			state = StateCancelled
+		} else if t.deadLetter(j, err) {
+			state = StateDeadLettered
 		}
+		t.setStatus(j, state, err)
+		taskOnError(ctx, task, err)
 		return
 	}
+	t.setStatus(j, StateSucceeded, nil)
 
 	if event, ok := task.(TaskDone); ok && event != nil {
 		event.Done(ctx)
 	}
 }
+
+func taskOnError(ctx context.Context, task Task, err error) {
+	if perr, ok := err.(*PanicError); ok {
+		if event, ok := task.(TaskOnPanic); ok && event != nil {
+			event.OnPanic(ctx, perr)
+			return
+		}
+	}
+	if event, ok := task.(TaskOnError); ok && event != nil {
+		event.OnError(ctx, err)
+	}
+}
@@ -56,3 +56,136 @@ func TestTaskOnErrorEvent(t *testing.T) {
 		t.Fail()
 	}
 }
+
+type panicTask struct {
+	fOnPanic func(context.Context, *taskq.PanicError)
+}
+
+func (t *panicTask) Do(_ context.Context) error {
+	panic("task failed")
+}
+
+func (t *panicTask) OnPanic(ctx context.Context, err *taskq.PanicError) {
+	t.fOnPanic(ctx, err)
+}
+
+func TestTaskOnPanicEvent(t *testing.T) {
+	rch := make(chan *taskq.PanicError)
+	tt := &panicTask{
+		fOnPanic: func(c context.Context, e *taskq.PanicError) {
+			rch <- e
+		},
+	}
+
+	tq := taskq.New(1)
+	tq.Start()
+	tq.Enqueue(context.Background(), tt)
+
+	select {
+	case pErr := <-rch:
+		if pErr.Value != "task failed" || len(pErr.Stack) == 0 {
+			t.Fatalf("invalid panic error. value=%v stack=%s", pErr.Value, pErr.Stack)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
+
+func TestTaskPanicFallbackToOnError(t *testing.T) {
+	rch := make(chan error)
+	expected := errors.New("panic err")
+	tt := &testTask{
+		fdo: func(ctx context.Context) error {
+			panic(expected)
+		},
+		fOnError: func(c context.Context, e error) {
+			rch <- e
+		},
+	}
+
+	// limit 1: the worker must survive the first panic for the second task
+	tq := taskq.New(1)
+	tq.Start()
+	tq.Enqueue(context.Background(), taskq.TaskFunc(func(ctx context.Context) error {
+		panic(expected)
+	}))
+	tq.Enqueue(context.Background(), tt)
+
+	select {
+	case err := <-rch:
+		var pErr *taskq.PanicError
+		if !errors.As(err, &pErr) || !errors.Is(err, expected) {
+			t.Fatalf("invalid error. expected=%s got=%s", expected, err)
+		}
+	case <-time.After(time.Second):
+		t.Fail()
+	}
+}
+
+type timeoutTask struct {
+	testTask
+	timeout time.Duration
+}
+
+func (t *timeoutTask) Timeout() time.Duration {
+	return t.timeout
+}
+
+func TestTaskTimeout(t *testing.T) {
+	rch := make(chan error)
+	tt := &timeoutTask{
+		testTask: testTask{
+			fdo: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			fOnError: func(c context.Context, e error) {
+				rch <- e
+			},
+		},
+		timeout: 10 * time.Millisecond,
+	}
+
+	tq := taskq.New(1)
+	tq.DefaultTimeout = time.Hour
+	tq.Start()
+	tq.Enqueue(context.Background(), tt)
+
+	select {
+	case err := <-rch:
+		if !errors.Is(err, taskq.ErrTaskTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrTaskTimeout, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not timed out")
+	}
+	if s := tq.Stats(); s.TimedOut != 1 || s.Failed != 1 {
+		t.Fatalf("invalid stats: %+v", s)
+	}
+}
+
+func TestTaskDefaultTimeout(t *testing.T) {
+	rch := make(chan error)
+	tq := taskq.New(1)
+	tq.DefaultTimeout = 10 * time.Millisecond
+	tq.Start()
+	tq.Enqueue(context.Background(), &testTask{
+		fdo: func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("interrupted")
+		},
+		fOnError: func(c context.Context, e error) {
+			rch <- e
+		},
+	})
+
+	select {
+	case err := <-rch:
+		var tErr *taskq.TimeoutError
+		if !errors.As(err, &tErr) || tErr.Timeout != tq.DefaultTimeout {
+			t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrTaskTimeout, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task not timed out")
+	}
+}
@@ -3,7 +3,7 @@ package taskq
 import (
 	"context"
 	"errors"
-	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -12,23 +12,112 @@ var (
 	ErrStarted = errors.New("taskq started")
 	ErrClosed  = errors.New("taskq closed")
 	ErrNilTask = errors.New("nil task")
+	// ErrShutdownDeadline is a cause of canceling active tasks when Shutdown context is done
+	ErrShutdownDeadline = errors.New("shutdown deadline exceeded")
 )
 
-type worker struct {
-	id uint64
-}
-
 type TaskQ struct {
+	counters counters
+
 	queue Queue
+	// base context of all tasks
+	ctx    context.Context
+	cancel func(error)
 
 	isRunning int32
 	isClosed  int32
 	isStopped int32
+	isPaused  int32
 
 	workerCount int32
-	workers     chan worker
+	pool        pool
+	// signal is set when new job is available for workers
+	signal int32
+	// number of idle workers waiting for new jobs
+	idle int32
+	// minimum number of idle workers waiting without timeout
+	warm int32
+	// timeout of waiting for new jobs by idle worker
+	idleTimeout int64
+	wake        chan struct{}
+	// closing is closed on Shutdown for releasing idle workers
+	closing chan struct{}
+	// halt is closed on Pause and Stop for releasing idle workers and polling
+	haltLock sync.Mutex
+	halt     chan struct{}
+
+	// number of jobs in ready, delayed, throttled and requeued lists and waiting for partitions
+	pending int32
+	// number of workers holding ConcurrencyLimiter slot
+	active int32
+	// polled is set when a task was dequeued since the last poll
+	polled int32
+	// running tasks for Cancel
+	running runningJobs
+	// listening is set while a worker waits in BlockingQueue
+	listening    int32
+	listenLock   sync.Mutex
+	listenCancel context.CancelFunc
+	// jobs ready for execution before dequeuing from queue
+	ready jobList
+	// jobs waiting for their next attempt
+	delayed delayedJobs
+	// empty jobs for waking up workers when scheduled tasks are due
+	wakeups delayedJobs
+	// empty jobs for waking up workers when retries returned into AckQueue are due
+	requeued delayedJobs
+	// jobs waiting for rate limit tokens
+	throttled delayedJobs
+	rates     rateLimits
+	// partitions with active jobs and jobs waiting for them
+	partitions partitions
+	nextLock   sync.Mutex
+
+	// enqueueLock serializes Enqueue calls when Capacity is set
+	enqueueLock sync.Mutex
+	space       spaceSignal
 
 	OnDequeueError func(ctx context.Context, workerID uint64, err error)
+	// CrashOnPanic disables recovering of Task.Do panics.
+	// By default panic is recovered and passed to task as PanicError
+	CrashOnPanic bool
+	// InheritCancel cancels task context when context passed to Enqueue is done.
+	// Task always inherits values of enqueue context if Queue implements ItemQueue
+	InheritCancel bool
+	// ShutdownGracePeriod is a time given to active tasks for finishing
+	// after their contexts were canceled by Shutdown
+	ShutdownGracePeriod time.Duration
+	// DefaultTimeout limits Task.Do execution time of tasks which don't implement TaskTimeout
+	DefaultTimeout time.Duration
+	// RetryPolicy of tasks which don't implement TaskRetryPolicy. Nil disables retries
+	RetryPolicy *RetryPolicy
+	// Capacity limits the number of tasks in queue. Queue must implement SizedQueue.
+	// Zero means no limit
+	Capacity int
+	// OverflowPolicy decides what to do with a new task when queue reached Capacity
+	OverflowPolicy OverflowPolicy
+	// ConcurrencyLimiter adjusts the number of concurrently running tasks within worker limit.
+	// Nil means all workers run tasks
+	ConcurrencyLimiter ConcurrencyLimiter
+	// OnAckError is called when Ack or Nack of AckQueue delivery failed
+	OnAckError func(ctx context.Context, taskID int64, err error)
+	// DeadLetter receives failed tasks as DeadTask when all retries are exhausted
+	// or task was delivered more than MaxDeliveries times. Nil disables dead-lettering
+	DeadLetter        Queue
+	OnDeadLetterError func(ctx context.Context, taskID int64, err error)
+	// MaxDeliveries quarantines task redelivered by AckQueue more than MaxDeliveries times
+	// without running it. Retries returned into queue by RetryDelivery are not counted. Zero means no limit
+	MaxDeliveries int
+	// StatusStore keeps task statuses for TaskQ.Status. Nil disables tracking
+	StatusStore StatusStore
+	// RateLimit limits the rate of task starts. Nil means no limit
+	RateLimit *RateLimit
+	// KeyRateLimits limits the rate of starts of tasks implementing TaskRateKey by their keys
+	// in addition to RateLimit. Tasks with keys not in map are not limited by key
+	KeyRateLimits map[string]RateLimit
+	// Poll enables polling of queue, so tasks enqueued by other processes are executed.
+	// Nil means workers are started only by Enqueue
+	Poll *PollPolicy
 }
 
 func New(limit int) *TaskQ {
@@ -36,58 +125,239 @@ func New(limit int) *TaskQ {
 }
 
 func NewWithQueue(limit int, q Queue) *TaskQ {
-	if limit <= 0 {
-		limit = runtime.NumCPU()
-	}
-	// init worker pool
-	workers := make(chan worker, limit)
-	for i := 1; i < limit+1; i++ {
-		workers <- worker{
-			id: uint64(i),
-		}
-	}
-	return &TaskQ{
+	ctx, cancel := withCancelCause(context.Background())
+	t := &TaskQ{
 		queue:          q,
+		ctx:            ctx,
+		cancel:         cancel,
 		isRunning:      0,
 		isClosed:       0,
 		isStopped:      0,
-		workers:        workers,
+		wake:           make(chan struct{}, 1),
+		closing:        make(chan struct{}),
+		halt:           make(chan struct{}),
 		OnDequeueError: nil,
+		StatusStore:    NewStatusTracker(DefaultStatusTrackerSize),
+	}
+	// init worker pool
+	t.pool.resize(limit)
+	t.delayed.due = func(j *job) {
+		t.ready.push(j)
+		t.notify()
+	}
+	t.throttled.due = t.delayed.due
+	t.wakeups.due = func(*job) {
+		t.notify()
+	}
+	t.requeued.due = func(*job) {
+		t.notify()
+		atomic.AddInt32(&t.pending, -1)
 	}
+	if n, ok := q.(Notifier); ok {
+		n.Notify(t.notify)
+	}
+	return t
 }
 
-func (t *TaskQ) triggerDequeue(ctx context.Context) bool {
-	if atomic.LoadInt32(&t.isRunning) != 1 {
-		return false
+func (t *TaskQ) dequeue(ctx context.Context) (Item, error) {
+	var it Item
+	var err error
+	if q, ok := t.queue.(ItemQueue); ok {
+		it, err = q.DequeueItem(ctx)
+	} else {
+		it.Task, err = t.queue.Dequeue(ctx)
+	}
+	if err == nil {
+		t.dequeued()
+	}
+	return it, err
+}
+
+// dequeued updates TaskQ state after task was taken from queue
+func (t *TaskQ) dequeued() {
+	if t.Capacity > 0 {
+		t.space.broadcast()
+	}
+	if t.Poll != nil {
+		atomic.StoreInt32(&t.polled, 1)
+	}
+}
+
+// next returns a job ready for execution or errPartitionBusy if job waits for its partition.
+// Dequeuing and entering partition are serialized for keeping order of partition jobs
+func (t *TaskQ) next(ctx context.Context) (*job, error) {
+	t.nextLock.Lock()
+	defer t.nextLock.Unlock()
+	j, err := t.take(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return j, t.enterPartition(j)
+}
+
+// take returns a job ready for execution. Retried jobs have priority over queue
+func (t *TaskQ) take(ctx context.Context) (*job, error) {
+	if j := t.ready.pop(); j != nil {
+		atomic.AddInt32(&t.pending, -1)
+		return j, nil
+	}
+	if q, ok := t.queue.(AckQueue); ok {
+		return t.dequeueDelivery(ctx, q)
+	}
+	it, err := t.dequeue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &job{Item: it, attempt: 1}, nil
+}
+
+func (t *TaskQ) runJob(j *job) {
+	ctx, cancel, err := t.taskContext(j)
+	defer cancel(nil)
+	// retries returned into queue are not redeliveries
+	if err == nil && t.MaxDeliveries > 0 && j.Deliveries-j.Retries > t.MaxDeliveries {
+		err = ErrMaxDeliveries
+	}
+	if err != nil {
+		state := StateCancelled
+		if err == ErrMaxDeliveries {
+			state = StateFailed
+			if t.deadLetter(j, err) {
+				state = StateDeadLettered
+			}
+		}
+		t.setStatus(j, state, err)
+		t.counters.add(err)
+		taskOnError(ctx, j.Task, err)
+		t.settle(j, err)
+		t.leavePartition(j)
+		return
+	}
+	if j.ID > 0 {
+		t.running.add(j.ID, cancel)
+		defer t.running.remove(j.ID)
+	}
+	j.started = time.Now()
+	t.setStatus(j, StateRunning, nil)
+	t.processTask(ctx, j)
+}
+
+// notify wakes up an idle or a free worker for a new job
+func (t *TaskQ) notify() {
+	atomic.StoreInt32(&t.signal, 1)
+	if atomic.LoadInt32(&t.listening) == 1 {
+		t.stopListen()
+	}
+	if atomic.LoadInt32(&t.idle) > 0 {
+		select {
+		case t.wake <- struct{}{}:
+			return
+		default:
+		}
+	}
+	t.triggerDequeue()
+}
+
+// park waits for a new job on empty queue. Returns false if worker should exit
+func (t *TaskQ) park() bool {
+	idle := atomic.AddInt32(&t.idle, 1)
+	defer atomic.AddInt32(&t.idle, -1)
+	timeout := time.Duration(atomic.LoadInt64(&t.idleTimeout))
+	halt := t.haltSignal()
+	var expired <-chan time.Time
+	if idle > atomic.LoadInt32(&t.warm) {
+		if timeout <= 0 {
+			return false
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	if atomic.LoadInt32(&t.signal) == 1 {
+		return true
 	}
 	select {
+	case <-t.wake:
+		return true
+	case <-expired:
+		return false
+	case <-t.closing:
+		return false
+	case <-halt:
+		return false
+	}
+}
+
+// KeepIdleWorkers sets how workers wait for new tasks on empty queue:
+// `warm` workers wait without timeout, others exit after `timeout`
+func (t *TaskQ) KeepIdleWorkers(warm int, timeout time.Duration) {
+	atomic.StoreInt32(&t.warm, int32(warm))
+	atomic.StoreInt64(&t.idleTimeout, int64(timeout))
+	t.triggerFreeWorkers()
+}
+
+func (t *TaskQ) triggerDequeue() bool {
+	if t.halted() || atomic.LoadInt32(&t.isStopped) == 1 {
+		return false
+	}
+	if t.overLimit() {
+		return false
+	}
 	// trying to fetch free worker for immediate task execution
-	case w, ok := <-t.workers:
-		if !ok {
-			return false
+	w, ok := t.pool.acquire()
+	if !ok {
+		return false
+	}
+	atomic.AddInt32(&t.workerCount, 1)
+	go t.work(w)
+	return true
+}
+
+func (t *TaskQ) work(w worker) {
+	ctx := t.ctx
+	retired := false
+	for atomic.LoadInt32(&t.isStopped) != 1 && !t.halted() {
+		if t.pool.retireBusy() {
+			retired = true
+			break
+		}
+		if !t.acquireSlot() {
+			break
 		}
-		atomic.AddInt32(&t.workerCount, 1)
-		go func(ctx context.Context, w worker) {
-			for atomic.LoadInt32(&t.isStopped) != 1 {
-				task, err := t.queue.Dequeue(ctx)
-				if err != nil {
-					if err == EmptyQueue {
-						break
-					}
-					if t.OnDequeueError == nil {
-						panic(err)
-					}
-					t.OnDequeueError(ctx, w.id, err)
-					break
+		atomic.StoreInt32(&t.signal, 0)
+		j, err := t.next(ctx)
+		if err == EmptyQueue && atomic.LoadInt32(&t.isClosed) == 0 {
+			j, err = t.listen()
+		}
+		if err != nil {
+			t.releaseSlot()
+			if err == errPartitionBusy {
+				continue
+			}
+			if err == EmptyQueue {
+				if atomic.LoadInt32(&t.isClosed) == 0 && t.park() {
+					continue
 				}
-				processTask(ctx, task)
+				break
 			}
-			t.workers <- w // return worker to pool
-			atomic.AddInt32(&t.workerCount, -1)
-		}(ctx, w)
-		return true
-	default:
-		return false
+			if t.OnDequeueError == nil {
+				panic(err)
+			}
+			t.OnDequeueError(ctx, w.id, err)
+			break
+		}
+		if !t.throttle(j) {
+			t.runJob(j)
+		}
+		t.releaseSlot()
+	}
+	if !retired {
+		t.pool.release(w) // return worker to pool
+	}
+	atomic.AddInt32(&t.workerCount, -1)
+	// job could be added after dequeue while this worker was still busy
+	if atomic.LoadInt32(&t.signal) == 1 && atomic.LoadInt32(&t.isStopped) != 1 {
+		t.triggerDequeue()
 	}
 }
 
@@ -100,49 +370,111 @@ func (t *TaskQ) Enqueue(ctx context.Context, task Task) (int64, error) {
 		return -1, ErrClosed
 	}
 
-	id, err := t.queue.Enqueue(ctx, task)
+	// status is timestamped before task can be dequeued
+	now := time.Now()
+	id, err := t.push(ctx, func() (int64, error) {
+		return t.queue.Enqueue(ctx, task)
+	})
+	if err == errCallerRuns {
+		if err := t.callerRuns(ctx, task); err != nil {
+			return -1, err
+		}
+		return 0, nil
+	}
 	if err != nil {
 		return -1, err
 	}
 
-	t.triggerDequeue(ctx)
+	t.saveStatus(TaskStatus{ID: id, State: StatePending, EnqueuedAt: now, UpdatedAt: now})
+	t.notify()
 	return id, nil
 }
 
-func (t *TaskQ) triggerFreeWorkers(ctx context.Context) {
-	count := len(t.workers)
+func (t *TaskQ) triggerFreeWorkers() {
+	count := t.pool.freeCount()
 	for i := 0; i < count; i++ {
-		if !t.triggerDequeue(ctx) {
+		if !t.triggerDequeue() {
 			return
 		}
 	}
 }
 
+// Resize changes the number of workers while TaskQ is running.
+// On shrinking, busy workers retire after finishing their current tasks
+func (t *TaskQ) Resize(limit int) {
+	t.pool.resize(limit)
+	t.triggerFreeWorkers()
+}
+
+// Limit returns the maximum number of workers
+func (t *TaskQ) Limit() int {
+	return t.pool.size()
+}
+
 func (t *TaskQ) Start() error {
 	if atomic.LoadInt32(&t.isClosed) != 0 {
 		return ErrClosed
 	}
+	if atomic.LoadInt32(&t.isRunning) != 0 {
+		return ErrStarted
+	}
+	// redeliver tasks abandoned by crashed consumers
+	if q, ok := t.queue.(ReclaimQueue); ok {
+		if _, err := q.Reclaim(t.ctx); err != nil {
+			return err
+		}
+	}
 	if !atomic.CompareAndSwapInt32(&t.isRunning, 0, 1) {
 		return ErrStarted
 	}
-	t.triggerFreeWorkers(context.Background())
+	t.updateHalt()
+	t.restart()
 	return nil
 }
 
+// Shutdown stops accepting new tasks and waits for active tasks to finish.
+// With ContextWithWait it also drains the queue.
+// When ctx is done, contexts of active tasks are canceled with ErrShutdownDeadline cause
+// and Shutdown waits ShutdownGracePeriod for tasks to return
 func (t *TaskQ) Shutdown(ctx context.Context) error {
 	if !atomic.CompareAndSwapInt32(&t.isClosed, 0, 1) {
 		return ErrClosed
 	}
-	defer close(t.workers)
-	if wait, _ := ctx.Value(ctxWaitKey{}).(bool); !wait {
+	defer t.dropPending()
+	close(t.closing)
+	t.stopListen()
+	wait, _ := ctx.Value(ctxWaitKey{}).(bool)
+	if !wait {
 		atomic.StoreInt32(&t.isStopped, 1)
 	} else {
-		t.triggerFreeWorkers(ctx)
+		// paused TaskQ is drained too
+		t.Resume()
+		t.triggerFreeWorkers()
+	}
+
+	err := t.waitWorkers(ctx, wait)
+	atomic.StoreInt32(&t.isStopped, 1)
+	if err == nil {
+		t.cancel(ErrClosed)
+		return nil
 	}
+	// hard cancel of active tasks
+	t.cancel(ErrShutdownDeadline)
+	if t.ShutdownGracePeriod > 0 {
+		graceCtx, cancel := context.WithTimeout(context.Background(), t.ShutdownGracePeriod)
+		defer cancel()
+		_ = t.waitWorkers(graceCtx, false)
+	}
+	return err
+}
 
+// waitWorkers waits until all workers are finished.
+// With `delayed` it also waits for jobs scheduled for retry
+func (t *TaskQ) waitWorkers(ctx context.Context, delayed bool) error {
 	var pollDuration = time.Millisecond * 500
 	timer := time.NewTimer(pollDuration)
-	for atomic.LoadInt32(&t.workerCount) > 0 {
+	defer timer.Stop()
+	for atomic.LoadInt32(&t.workerCount) > 0 || (delayed && atomic.LoadInt32(&t.pending) > 0) {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -154,6 +486,29 @@ func (t *TaskQ) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// dropPending reports the last error to tasks which were waiting for retry on shutdown
+// and ErrClosed to not started tasks which were waiting for rate limit tokens or partition.
+// Retries returned into AckQueue are reported as well and stay in queue for redelivery
+func (t *TaskQ) dropPending() {
+	t.wakeups.drain()
+	jobs := t.requeued.drain()
+	jobs = append(jobs, t.partitions.drain()...)
+	jobs = append(jobs, t.ready.drain()...)
+	jobs = append(jobs, t.delayed.drain()...)
+	jobs = append(jobs, t.throttled.drain()...)
+	atomic.AddInt32(&t.pending, -int32(len(jobs)))
+	for _, j := range jobs {
+		if j.err == nil {
+			// throttled job was not started yet
+			j.err = ErrClosed
+		}
+		t.counters.add(j.err)
+		t.setStatus(j, StateCancelled, j.err)
+		taskOnError(t.ctx, j.Task, j.err)
+		t.requeue(j)
+	}
+}
+
 func (t *TaskQ) Close() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -109,3 +109,37 @@ func TestCloseAfterClose_Err(t *testing.T) {
 		t.Fatalf("invalid error. expected=%s got=%s", taskq.ErrClosed, err)
 	}
 }
+
+func TestShutdownCancelsActiveTasksOnDeadline_Ok(t *testing.T) {
+	tq := taskq.New(1)
+	tq.ShutdownGracePeriod = time.Second
+	started := make(chan struct{})
+	var cause error
+	var cleanedUp int32
+	tf := taskq.TaskFunc(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		cause = taskq.Cause(ctx)
+		atomic.StoreInt32(&cleanedUp, 1)
+		return nil
+	})
+	if err := tq.Start(); err != nil {
+		panic(err)
+	}
+	if _, err := tq.Enqueue(context.Background(), tf); err != nil {
+		panic(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := tq.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected: %s actual %s", context.DeadlineExceeded, err)
+	}
+	if atomic.LoadInt32(&cleanedUp) != 1 {
+		t.Fatal("task didn't finish in grace period")
+	}
+	if cause != taskq.ErrShutdownDeadline {
+		t.Fatalf("invalid cause. expected: %s actual %s", taskq.ErrShutdownDeadline, cause)
+	}
+}
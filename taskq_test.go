@@ -13,19 +13,27 @@ import (
 type testTask struct {
 	fOnError func(context.Context, error)
 	fdone    func(context.Context)
+	fdo      func(context.Context) error
 
 	resultErr error
 }
 
 func (t *testTask) OnError(ctx context.Context, err error) {
-	t.fOnError(ctx, err)
+	if t.fOnError != nil {
+		t.fOnError(ctx, err)
+	}
 }
 
 func (t *testTask) Done(ctx context.Context) {
-	t.fdone(ctx)
+	if t.fdone != nil {
+		t.fdone(ctx)
+	}
 }
 
-func (t *testTask) Do(_ context.Context) error {
+func (t *testTask) Do(ctx context.Context) error {
+	if t.fdo != nil {
+		return t.fdo(ctx)
+	}
 	return t.resultErr
 }
 
@@ -1,26 +1,202 @@
 package taskq
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
 
+// MultiError is returned by WaitGroup.Wait with AllErrors
+type MultiError []error
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e MultiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// WaitGroup runs a group of tasks and waits for them. It can be reused after Wait
 type WaitGroup struct {
 	tq *TaskQ
+	// CancelOnError cancels contexts of other tasks of the group on the first error
+	CancelOnError bool
+	// AllErrors makes Wait return MultiError with errors of all failed tasks instead of the first one
+	AllErrors bool
+
+	lock sync.Mutex
+	// number of not finished tasks
+	active int
+	// done is closed when active tasks of the current generation are finished
+	done   chan struct{}
+	sem    chan struct{}
+	errs   []error
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
+// NewWaitGroup creates WaitGroup running tasks on a private TaskQ with `size` workers
 func NewWaitGroup(size int) *WaitGroup {
 	tq := New(size)
 	err := tq.Start()
 	if err != nil {
 		panic(err)
 	}
-	return &WaitGroup{
-		tq: tq,
+	return NewWaitGroupWithTaskQ(tq)
+}
+
+// NewWaitGroupWithTaskQ creates WaitGroup running tasks on shared TaskQ
+func NewWaitGroupWithTaskQ(tq *TaskQ) *WaitGroup {
+	wg := &WaitGroup{tq: tq}
+	wg.ctx, wg.cancel = context.WithCancel(context.Background())
+	return wg
+}
+
+// SetLimit limits the number of active tasks of the group, Enqueue blocks when limit is reached.
+// Zero or negative value means no limit. Must not be called while group has active tasks
+func (wg *WaitGroup) SetLimit(n int) {
+	wg.lock.Lock()
+	defer wg.lock.Unlock()
+	if n <= 0 {
+		wg.sem = nil
+		return
 	}
+	wg.sem = make(chan struct{}, n)
 }
 
 func (wg *WaitGroup) Enqueue(ctx context.Context, t Task) (int64, error) {
-	return wg.tq.Enqueue(ctx, t)
+	if t == nil {
+		return -1, ErrNilTask
+	}
+	wg.lock.Lock()
+	sem, groupCtx := wg.sem, wg.ctx
+	wg.lock.Unlock()
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return -1, ctx.Err()
+		}
+	}
+	wg.add()
+	id, err := wg.tq.Enqueue(ctx, &groupTask{task: t, wg: wg, ctx: groupCtx, sem: sem})
+	if err != nil {
+		wg.release(sem)
+	}
+	return id, err
+}
+
+// Wait waits for all enqueued tasks and returns the first error of the group.
+// Returns ctx error if ctx is done first, in this case tasks keep running and Wait can be called again
+func (wg *WaitGroup) Wait(ctx context.Context) error {
+	wg.lock.Lock()
+	done := wg.done
+	wg.lock.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	wg.lock.Lock()
+	defer wg.lock.Unlock()
+	errs := wg.errs
+	wg.errs = nil
+	// tasks enqueued after the group was finished keep the group context
+	if wg.active == 0 {
+		wg.cancel()
+		wg.ctx, wg.cancel = context.WithCancel(context.Background())
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case wg.AllErrors:
+		return MultiError(errs)
+	default:
+		return errs[0]
+	}
+}
+
+func (wg *WaitGroup) finish(sem chan struct{}, err error) {
+	if err != nil {
+		wg.lock.Lock()
+		wg.errs = append(wg.errs, err)
+		if wg.CancelOnError {
+			wg.cancel()
+		}
+		wg.lock.Unlock()
+	}
+	wg.release(sem)
+}
+
+// add starts a new generation of the group when it has no active tasks
+func (wg *WaitGroup) add() {
+	wg.lock.Lock()
+	if wg.active == 0 {
+		wg.done = make(chan struct{})
+	}
+	wg.active++
+	wg.lock.Unlock()
+}
+
+func (wg *WaitGroup) release(sem chan struct{}) {
+	if sem != nil {
+		<-sem
+	}
+	wg.lock.Lock()
+	wg.active--
+	if wg.active == 0 {
+		close(wg.done)
+	}
+	wg.lock.Unlock()
+}
+
+// groupTask is a task of WaitGroup
+type groupTask struct {
+	task Task
+	wg   *WaitGroup
+	ctx  context.Context
+	sem  chan struct{}
+}
+
+func (t *groupTask) Unwrap() Task {
+	return t.task
+}
+
+// Do runs task with context canceled together with the group context
+func (t *groupTask) Do(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return t.task.Do(ctx)
+}
+
+func (t *groupTask) Done(ctx context.Context) {
+	if event, ok := t.task.(TaskDone); ok && event != nil {
+		event.Done(ctx)
+	}
+	t.wg.finish(t.sem, nil)
 }
 
-func (wg *WaitGroup) Wait() {
-	wg.tq.Shutdown(ContextWithWait(context.Background()))
+func (t *groupTask) OnError(ctx context.Context, err error) {
+	taskOnError(ctx, t.task, err)
+	t.wg.finish(t.sem, err)
 }
@@ -2,8 +2,10 @@ package taskq
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestWaitGroupEnqueue(t *testing.T) {
@@ -16,9 +18,115 @@ func TestWaitGroupEnqueue(t *testing.T) {
 			return nil
 		}))
 	}
-	wg.Wait()
+	if err := wg.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	if res := atomic.LoadInt64(&result); res != expected {
 		t.Fatalf("invalid result number. expected=%d got=%d", expected, res)
 	}
 }
+
+func TestWaitGroupFirstError(t *testing.T) {
+	wg := NewWaitGroup(1)
+	err1, err2 := errors.New("err1"), errors.New("err2")
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error { return err1 }))
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error { return err2 }))
+	if err := wg.Wait(context.Background()); err != err1 {
+		t.Fatalf("invalid error. expected=%s got=%v", err1, err)
+	}
+	// group is reusable after Wait
+	wg.AllErrors = true
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error { return err1 }))
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error { return err2 }))
+	err := wg.Wait(context.Background())
+	if merr, ok := err.(MultiError); !ok || len(merr) != 2 || !errors.Is(err, err2) {
+		t.Fatalf("invalid error: %v", err)
+	}
+}
+
+func TestWaitGroupCancelOnError(t *testing.T) {
+	wg := NewWaitGroup(2)
+	wg.CancelOnError = true
+	testErr := errors.New("test")
+	var cancelled int32
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+		return ctx.Err()
+	}))
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error { return testErr }))
+	if err := wg.Wait(context.Background()); err != testErr {
+		t.Fatalf("invalid error. expected=%s got=%v", testErr, err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Fatal("sibling task wasn't cancelled")
+	}
+}
+
+func TestWaitGroupSetLimit(t *testing.T) {
+	tq := New(4)
+	tq.Start()
+	defer tq.Close()
+	wg := NewWaitGroupWithTaskQ(tq)
+	wg.SetLimit(1)
+	var active, max int32
+	for i := 0; i < 5; i++ {
+		wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error {
+			if a := atomic.AddInt32(&active, 1); a > atomic.LoadInt32(&max) {
+				atomic.StoreInt32(&max, a)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			return nil
+		}))
+	}
+	if err := wg.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if max != 1 {
+		t.Fatalf("invalid max active tasks. expected=1 got=%d", max)
+	}
+}
+
+func TestWaitGroupWaitTimeout(t *testing.T) {
+	wg := NewWaitGroup(1)
+	release := make(chan struct{})
+	wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := wg.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("invalid error. expected=%s got=%v", context.DeadlineExceeded, err)
+	}
+	close(release)
+	if err := wg.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitGroupReuseAfterTimeout(t *testing.T) {
+	tq := New(4)
+	tq.Start()
+	defer tq.Close()
+	wg := NewWaitGroupWithTaskQ(tq)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	var result int64
+	for i := 0; i < 100; i++ {
+		wg.Enqueue(context.Background(), TaskFunc(func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&result, 1)
+			return nil
+		}))
+		wg.Wait(cancelled)
+	}
+	if err := wg.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if res := atomic.LoadInt64(&result); res != 100 {
+		t.Fatalf("invalid result number. expected=100 got=%d", res)
+	}
+}